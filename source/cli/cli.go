@@ -0,0 +1,350 @@
+// Package cli implements the command line interface of a node.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"os"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+)
+
+// CLI responsible for processing command line arguments
+type CLI struct{}
+
+func (cli *CLI) printUsage() {
+	fmt.Println("Usage: [-network NETWORK] COMMAND")
+	fmt.Println("  -network NETWORK - mainnet (default), testnet, regtest or the path of a JSON file with custom network parameters")
+	fmt.Println("Commands:")
+	fmt.Println("  createblockchain - Create a blockchain holding the genesis block of the network")
+	fmt.Println("  createwallet - Generates a new key-pair and saves it into the wallet file")
+	fmt.Println("  dumputxo -file FILE - Writes a snapshot of the UTXO set and the headers of the main chain to FILE")
+	fmt.Println("  exportchain -file FILE - Writes the blocks of the main chain to FILE")
+	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
+	fmt.Println("  getblock -height HEIGHT | -hash HASH - Print the main chain block at HEIGHT or the block with HASH")
+	fmt.Println("  getblockhash -height HEIGHT - Print the hash of the main chain block at HEIGHT")
+	fmt.Println("  importchain -file FILE - Validates and adds the blocks exported to FILE. Run it again to resume an interrupted import")
+	fmt.Println("  listaddresses - Lists all addresses from the wallet file")
+	fmt.Println("  listtransactions -address ADDRESS - Lists the transactions of ADDRESS, requires the address index")
+	fmt.Println("  loadutxo -file FILE - Starts a blockchain created by createblockchain from the UTXO snapshot in FILE, which the network must pin")
+	fmt.Println("  printchain - Print all the blocks of the blockchain")
+	fmt.Println("  reindexutxo - Rebuilds the UTXO set")
+	fmt.Println("  rollback -to HEIGHT - Disconnects and removes the blocks above HEIGHT")
+	fmt.Println("  reindextx - Rebuilds the transaction index")
+	fmt.Println("  reindexaddr - Builds or rebuilds the address index and keeps it up to date from then on")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT -fee FEE -mine - Send AMOUNT of coins from FROM address to TO, paying FEE to the miner. Mine on the same node, when -mine is set.")
+	fmt.Println("  startnode -miner ADDRESS -prune DEPTH - Start a node with ID specified in NODE_ID env. var. -miner enables mining, -prune keeps only the last DEPTH blocks in full")
+	fmt.Println("  verifychain -level LEVEL - Checks the blockchain DB up to LEVEL: 1 headers and proof-of-work, 2 Merkle roots, 3 signatures, 4 (default) the UTXO set")
+}
+
+// validateArgs 检查命令行在全局参数之后是否还有命令
+func (cli *CLI) validateArgs(args []string) {
+	if len(args) < 1 {
+		cli.printUsage()
+		os.Exit(1)
+	}
+}
+
+// Run parses command line arguments and processes commands
+func (cli *CLI) Run() {
+	globalFlags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	network := globalFlags.String("network", params.MainNet.Name, "The network to run on, or a JSON file with its parameters")
+	globalFlags.Usage = cli.printUsage
+	err := globalFlags.Parse(os.Args[1:])
+	if err != nil {
+		log.Panic(err)
+	}
+
+	args := globalFlags.Args()
+	cli.validateArgs(args)
+
+	if err := params.SelectNetwork(*network); err != nil {
+		fmt.Printf("Can't use network %s: %v\n", *network, err)
+		os.Exit(1)
+	}
+
+	// 从运行环境获取 NODE_ID
+	nodeID := os.Getenv("NODE_ID")
+	if nodeID == "" {
+		fmt.Printf("NODE_ID env. var is not set!")
+		os.Exit(1)
+	}
+
+	dumpUTXOCmd := flag.NewFlagSet("dumputxo", flag.ExitOnError)
+	exportChainCmd := flag.NewFlagSet("exportchain", flag.ExitOnError)
+	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	getBlockCmd := flag.NewFlagSet("getblock", flag.ExitOnError)
+	getBlockHashCmd := flag.NewFlagSet("getblockhash", flag.ExitOnError)
+	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	importChainCmd := flag.NewFlagSet("importchain", flag.ExitOnError)
+	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	listTransactionsCmd := flag.NewFlagSet("listtransactions", flag.ExitOnError)
+	loadUTXOCmd := flag.NewFlagSet("loadutxo", flag.ExitOnError)
+	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
+	reindexTxCmd := flag.NewFlagSet("reindextx", flag.ExitOnError)
+	reindexAddrCmd := flag.NewFlagSet("reindexaddr", flag.ExitOnError)
+	rollbackCmd := flag.NewFlagSet("rollback", flag.ExitOnError)
+	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	verifyChainCmd := flag.NewFlagSet("verifychain", flag.ExitOnError)
+
+	dumpUTXOFile := dumpUTXOCmd.String("file", "", "The file to write the snapshot to")
+	exportChainFile := exportChainCmd.String("file", "", "The file to export the blocks to")
+	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	getBlockHeight := getBlockCmd.Int("height", -1, "The height of the block")
+	getBlockHash := getBlockCmd.String("hash", "", "The hash of the block")
+	getBlockHashHeight := getBlockHashCmd.Int("height", -1, "The height of the block")
+	importChainFile := importChainCmd.String("file", "", "The file to import the blocks from")
+	listTransactionsAddress := listTransactionsCmd.String("address", "", "The address to list transactions for")
+	loadUTXOFile := loadUTXOCmd.String("file", "", "The file to load the snapshot from")
+	rollbackTo := rollbackCmd.Int("to", -1, "The height to roll the chain back to")
+	sendFrom := sendCmd.String("from", "", "Source wallet address")
+	sendTo := sendCmd.String("to", "", "Destination wallet address")
+	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendFee := sendCmd.Int("fee", 0, "Fee paid to the miner")
+	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
+	startNodePrune := startNodeCmd.Int("prune", 0, "Keep only the transactions of the last DEPTH blocks")
+	verifyChainLevel := verifyChainCmd.Int("level", core.VerifyUTXO, "How thoroughly to check the blockchain, from 1 to 4")
+
+	switch args[0] {
+	case "dumputxo":
+		err := dumpUTXOCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "exportchain":
+		err := exportChainCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getbalance":
+		err := getBalanceCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getblock":
+		err := getBlockCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getblockhash":
+		err := getBlockHashCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createblockchain":
+		err := createBlockchainCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createwallet":
+		err := createWalletCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importchain":
+		err := importChainCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listaddresses":
+		err := listAddressesCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listtransactions":
+		err := listTransactionsCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "loadutxo":
+		err := loadUTXOCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "printchain":
+		err := printChainCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "reindexutxo":
+		err := reindexUTXOCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "reindextx":
+		err := reindexTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "reindexaddr":
+		err := reindexAddrCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "rollback":
+		err := rollbackCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "send":
+		err := sendCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "startnode":
+		err := startNodeCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifychain":
+		err := verifyChainCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	default:
+		cli.printUsage()
+		os.Exit(1)
+	}
+
+	if dumpUTXOCmd.Parsed() {
+		if *dumpUTXOFile == "" {
+			dumpUTXOCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.dumpUTXO(*dumpUTXOFile, nodeID)
+	}
+
+	if exportChainCmd.Parsed() {
+		if *exportChainFile == "" {
+			exportChainCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.exportChain(*exportChainFile, nodeID)
+	}
+
+	if getBalanceCmd.Parsed() {
+		if *getBalanceAddress == "" {
+			getBalanceCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.getBalance(*getBalanceAddress, nodeID)
+	}
+
+	if getBlockCmd.Parsed() {
+		if (*getBlockHeight < 0) == (*getBlockHash == "") {
+			getBlockCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.getBlock(*getBlockHeight, *getBlockHash, nodeID)
+	}
+
+	if getBlockHashCmd.Parsed() {
+		if *getBlockHashHeight < 0 {
+			getBlockHashCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.getBlockHash(*getBlockHashHeight, nodeID)
+	}
+
+	if createBlockchainCmd.Parsed() {
+		err = cli.createBlockchain(nodeID)
+	}
+
+	if createWalletCmd.Parsed() {
+		err = cli.createWallet(nodeID)
+	}
+
+	if importChainCmd.Parsed() {
+		if *importChainFile == "" {
+			importChainCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.importChain(*importChainFile, nodeID)
+	}
+
+	if listAddressesCmd.Parsed() {
+		err = cli.listAddresses(nodeID)
+	}
+
+	if listTransactionsCmd.Parsed() {
+		if *listTransactionsAddress == "" {
+			listTransactionsCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.listTransactions(*listTransactionsAddress, nodeID)
+	}
+
+	if loadUTXOCmd.Parsed() {
+		if *loadUTXOFile == "" {
+			loadUTXOCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.loadUTXO(*loadUTXOFile, nodeID)
+	}
+
+	if printChainCmd.Parsed() {
+		err = cli.printChain(nodeID)
+	}
+
+	if reindexUTXOCmd.Parsed() {
+		err = cli.reindexUTXO(nodeID)
+	}
+
+	if reindexTxCmd.Parsed() {
+		err = cli.reindexTx(nodeID)
+	}
+
+	if reindexAddrCmd.Parsed() {
+		err = cli.reindexAddr(nodeID)
+	}
+
+	if rollbackCmd.Parsed() {
+		if *rollbackTo < 0 {
+			rollbackCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.rollback(*rollbackTo, nodeID)
+	}
+
+	if sendCmd.Parsed() {
+		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 || *sendFee < 0 {
+			sendCmd.Usage()
+			os.Exit(1)
+		}
+
+		err = cli.send(*sendFrom, *sendTo, *sendAmount, *sendFee, nodeID, *sendMine)
+	}
+
+	if startNodeCmd.Parsed() {
+		nodeID := os.Getenv("NODE_ID")
+		if nodeID == "" {
+			startNodeCmd.Usage()
+			os.Exit(1)
+		}
+		if *startNodePrune != 0 && *startNodePrune < core.MinPruneDepth {
+			fmt.Printf("The prune depth must be at least %d\n", core.MinPruneDepth)
+			os.Exit(1)
+		}
+		err = cli.startNode(nodeID, *startNodeMiner, *startNodePrune)
+	}
+
+	if verifyChainCmd.Parsed() {
+		if *verifyChainLevel < core.VerifyHeaders || *verifyChainLevel > core.VerifyUTXO {
+			verifyChainCmd.Usage()
+			os.Exit(1)
+		}
+		err = cli.verifyChain(*verifyChainLevel, nodeID)
+	}
+
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+)
+
+func (cli *CLI) createBlockchain(nodeID string) error {
+	bc, err := core.CreateBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	fmt.Printf("Genesis block of %s: %x\n", params.Active.Name, bc.Tip())
+	fmt.Println("Done!")
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+func (cli *CLI) createWallet(nodeID string) error {
+	wallets, err := wallet.NewWallets(nodeID)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	address, err := wallets.CreateWallet()
+	if err != nil {
+		return err
+	}
+	if err := wallets.SaveToFile(nodeID); err != nil {
+		return err
+	}
+
+	fmt.Printf("Your new address: %s\n", address)
+
+	return nil
+}
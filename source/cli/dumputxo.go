@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) dumpUTXO(path, nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	snapshot, err := bc.DumpUTXO(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	fmt.Printf("Done! The UTXO set at height %d was written to %s.\n", snapshot.Height, path)
+	fmt.Println("Nodes load it once their network parameters pin it in UTXOSnapshots:")
+	fmt.Printf("  {\"Height\": %d, \"BlockHash\": \"%s\", \"ContentHash\": \"%s\"}\n", snapshot.Height, snapshot.BlockHash, snapshot.ContentHash)
+
+	return nil
+}
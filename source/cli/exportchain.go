@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) exportChain(path, nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	count, err := bc.ExportBlocks(w, func(height int) {
+		fmt.Printf("\rExported the block at height %d", height)
+	})
+	fmt.Println()
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	fmt.Printf("Done! %d blocks exported to %s.\n", count, path)
+
+	return nil
+}
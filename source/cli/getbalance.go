@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+// 获取指定地址的账户余额
+func (cli *CLI) getBalance(address, nodeID string) error {
+	if !wallet.ValidateAddress(address) {
+		return wallet.ErrInvalidAddress
+	}
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	defer bc.Close()
+
+	pubKeyHash := wallet.Base58Decode([]byte(address))
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+	balance, immature, err := UTXOSet.Balance(pubKeyHash)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Balance of '%s': %d\n", address, balance)
+	if immature > 0 {
+		fmt.Printf("Immature: %d (coinbase outputs spendable after %d blocks)\n", immature, params.Active.CoinbaseMaturity)
+	}
+
+	return nil
+}
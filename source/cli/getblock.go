@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) getBlockHash(height int, nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	hash, err := bc.GetBlockHash(height)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%x\n", hash)
+
+	return nil
+}
+
+// getBlock prints the block with the given hash or, when hash is empty, the
+// main chain block at the given height
+func (cli *CLI) getBlock(height int, hash, nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	var block core.Block
+
+	if hash != "" {
+		var blockHash []byte
+		blockHash, err = hex.DecodeString(hash)
+		if err != nil {
+			return err
+		}
+		block, err = bc.GetBlock(blockHash)
+	} else {
+		block, err = bc.GetBlockByHeight(height)
+	}
+	if err != nil {
+		return err
+	}
+
+	printBlock(&block)
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) importChain(path, nodeID string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	count, err := bc.ImportBlocks(f, func(height int) {
+		fmt.Printf("\rImported the block at height %d", height)
+	})
+	if count > 0 {
+		fmt.Println()
+	}
+	if err != nil {
+		return fmt.Errorf("%w (%d blocks were imported, run importchain again to resume)", err, count)
+	}
+
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done! %d blocks imported, the tip is now at height %d.\n", count, bestHeight)
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+func (cli *CLI) listAddresses(nodeID string) error {
+	wallets, err := wallet.NewWallets(nodeID)
+	if err != nil {
+		return err
+	}
+	addresses := wallets.GetAddresses()
+
+	for _, address := range addresses {
+		fmt.Println(address)
+	}
+
+	return nil
+}
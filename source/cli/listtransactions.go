@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+func (cli *CLI) listTransactions(address, nodeID string) error {
+	if !wallet.ValidateAddress(address) {
+		return wallet.ErrInvalidAddress
+	}
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	pubKeyHash := wallet.Base58Decode([]byte(address))
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+
+	history, err := bc.FindAddressTransactions(pubKeyHash)
+	if err != nil {
+		return err
+	}
+
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	for _, at := range history {
+		direction := "incoming"
+		amount := at.Received - at.Sent
+		if amount < 0 {
+			direction = "outgoing"
+			amount = -amount
+		}
+
+		date := time.Unix(at.Timestamp, 0).Format("2006-01-02 15:04:05")
+		confirmations := bestHeight - at.Height + 1
+		fmt.Printf("%s  %-8s %6d  %x  (%d confirmations)\n", date, direction, amount, at.TxID, confirmations)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) loadUTXO(path, nodeID string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	snapshot, err := bc.LoadUTXOSnapshot(f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done! The tip is now block %s at height %d.\n", snapshot.BlockHash, snapshot.Height)
+	fmt.Println("The historical blocks are validated in the background once the node is started.")
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) printChain(nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	bci := bc.Iterator()
+
+	for {
+		header, err := bci.Next()
+		if err != nil {
+			return err
+		}
+		block, err := bc.GetBlock(header.Hash())
+		if err != nil {
+			return err
+		}
+
+		printBlock(&block)
+
+		if len(header.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return nil
+}
+
+func printBlock(block *core.Block) {
+	fmt.Printf("============ Block %x ============\n", block.Hash)
+	fmt.Printf("Height: %d\n", block.Height)
+	fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
+	fmt.Printf("Version: %d\n", block.Version)
+	fmt.Printf("Merkle root: %x\n", block.MerkleRoot)
+	fmt.Printf("Bits: %08x\n", block.Bits)
+	if block.IsPruned() {
+		fmt.Printf("Transactions pruned\n\n\n")
+		return
+	}
+	pow := core.NewProofOfWork(&block.BlockHeader)
+	fmt.Printf("PoW: %s\n\n", strconv.FormatBool(pow.Validate()))
+	for _, tx := range block.Transactions {
+		fmt.Println(tx)
+	}
+	fmt.Printf("\n\n")
+}
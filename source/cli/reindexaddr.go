@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) reindexAddr(nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	count, err := bc.ReindexAddresses()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done! There are %d entries in the address index.\n", count)
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) reindexTx(nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	count, err := bc.ReindexTransactions()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done! There are %d transactions in the transaction index.\n", count)
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) reindexUTXO(nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	if err := UTXOSet.Reindex(); err != nil {
+		return err
+	}
+
+	count, err := UTXOSet.CountTransactions()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
+
+	return nil
+}
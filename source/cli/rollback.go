@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) rollback(height int, nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	disconnected, err := bc.RollbackTo(height)
+	if err != nil {
+		return err
+	}
+	for _, block := range disconnected {
+		fmt.Printf("Disconnected block %x at height %d\n", block.Hash, block.Height)
+	}
+
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done! The tip is now at height %d.\n", bestHeight)
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+	"github.com/aQuaYi/Blockchain-in-Go/source/p2p"
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+func (cli *CLI) send(from, to string, amount, fee int, nodeID string, mineNow bool) error {
+	if !wallet.ValidateAddress(from) {
+		return fmt.Errorf("sender: %w", wallet.ErrInvalidAddress)
+	}
+	if !wallet.ValidateAddress(to) {
+		return fmt.Errorf("recipient: %w", wallet.ErrInvalidAddress)
+	}
+
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	UTXOSet := core.UTXOSet{Blockchain: bc}
+	defer bc.Close()
+
+	wallets, err := wallet.NewWallets(nodeID)
+	if err != nil {
+		return err
+	}
+	w, err := wallets.GetWallet(from)
+	if err != nil {
+		return err
+	}
+
+	tx, err := core.NewUTXOTransaction(&w, to, amount, fee, &UTXOSet)
+	if err != nil {
+		return err
+	}
+
+	if mineNow {
+		if _, err := bc.MineBlock(from, []*transaction.Transaction{tx}); err != nil {
+			return err
+		}
+	} else {
+		if err := p2p.SendTx(params.Active.KnownNodes[0], tx); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Success!")
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/p2p"
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+func (cli *CLI) startNode(nodeID, minerAddress string, pruneDepth int) error {
+	fmt.Printf("Starting node %s\n", nodeID)
+	if len(minerAddress) > 0 {
+		if wallet.ValidateAddress(minerAddress) {
+			fmt.Println("Mining is on. Address to receive rewards: ", minerAddress)
+		} else {
+			return fmt.Errorf("miner: %w", wallet.ErrInvalidAddress)
+		}
+	}
+	if pruneDepth > 0 {
+		fmt.Printf("Pruning is on. Keeping the transactions of the last %d blocks\n", pruneDepth)
+	}
+
+	return p2p.StartServer(nodeID, minerAddress, pruneDepth)
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+)
+
+func (cli *CLI) verifyChain(level int, nodeID string) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	count, err := bc.VerifyChain(level)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Done! %d blocks passed verification at level %d.\n", count, level)
+
+	return nil
+}
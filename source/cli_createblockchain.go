@@ -1,19 +0,0 @@
-package main
-
-import (
-	"fmt"
-	"log"
-)
-
-func (cli *CLI) createBlockchain(address, nodeID string) {
-	if !ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
-	}
-	bc := CreateBlockchain(address, nodeID)
-	defer bc.db.Close()
-
-	UTXOSet := UTXOSet{bc}
-	UTXOSet.Reindex()
-
-	fmt.Println("Done!")
-}
@@ -1,18 +0,0 @@
-package main
-
-import (
-	"fmt"
-	"log"
-)
-
-func (cli *CLI) listAddresses(nodeID string) {
-	wallets, err := NewWallets(nodeID)
-	if err != nil {
-		log.Panic(err)
-	}
-	addresses := wallets.GetAddresses()
-
-	for _, address := range addresses {
-		fmt.Println(address)
-	}
-}
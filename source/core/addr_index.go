@@ -0,0 +1,247 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+// addrIndexBucket holds the optional address index. It is maintained only
+// when the bucket exists, which ReindexAddresses takes care of.
+const addrIndexBucket = "addrindex"
+
+// ErrAddressIndexDisabled is returned when the address index is queried but
+// was never built
+var ErrAddressIndexDisabled = errors.New("address index is disabled, run reindexaddr to build it")
+
+// AddressTransaction is an entry of the history of an address: a main chain
+// transaction that pays to the address, spends its outputs, or both
+type AddressTransaction struct {
+	TxID      []byte
+	BlockHash []byte
+	Height    int
+	Timestamp int64
+	Received  int
+	Sent      int
+}
+
+// Serialize returns the canonical encoding of the AddressTransaction:
+//
+//	TxID      bytes
+//	BlockHash bytes
+//	Height    varint
+//	Timestamp int64
+//	Received  varint
+//	Sent      varint
+func (at AddressTransaction) Serialize() []byte {
+	var w utils.Writer
+
+	w.VarBytes(at.TxID)
+	w.VarBytes(at.BlockHash)
+	w.Varint(int64(at.Height))
+	w.Int64(at.Timestamp)
+	w.Varint(int64(at.Received))
+	w.Varint(int64(at.Sent))
+
+	return w.Data()
+}
+
+// DeserializeAddressTransaction decodes an AddressTransaction from its
+// canonical encoding
+func DeserializeAddressTransaction(data []byte) (AddressTransaction, error) {
+	r := utils.NewReader(data)
+	at := AddressTransaction{
+		TxID:      r.VarBytes(),
+		BlockHash: r.VarBytes(),
+		Height:    r.Int(),
+		Timestamp: r.Int64(),
+		Received:  r.Int(),
+		Sent:      r.Int(),
+	}
+
+	if err := r.Finish(); err != nil {
+		return AddressTransaction{}, fmt.Errorf("address transaction: %w", err)
+	}
+
+	return at, nil
+}
+
+// addrIndexKey orders the entries of an address by height and position, so
+// a cursor seeking to the public key hash walks its history in chain order
+func addrIndexKey(pubKeyHash []byte, height, position int) []byte {
+	key := append([]byte{}, pubKeyHash...)
+	key = append(key, utils.IntToHex(int64(height))...)
+
+	return append(key, utils.IntToHex(int64(position))...)
+}
+
+// indexAddresses adds the transactions of a block connected to the main chain
+// to the history of every address they touch. The values of the spent
+// outputs are taken from the undo data of the block. The number of added
+// entries is returned.
+func indexAddresses(a storage.Bucket, block *Block, undo BlockUndo) (int, error) {
+	counter := 0
+	spent := undo.Spent
+
+	for position, tx := range block.Transactions {
+		entries := make(map[string]*AddressTransaction)
+		entry := func(pubKeyHash []byte) *AddressTransaction {
+			if entries[string(pubKeyHash)] == nil {
+				entries[string(pubKeyHash)] = &AddressTransaction{
+					TxID:      tx.ID,
+					BlockHash: block.Hash,
+					Height:    block.Height,
+					Timestamp: block.Timestamp,
+				}
+			}
+
+			return entries[string(pubKeyHash)]
+		}
+
+		if !tx.IsCoinbase() {
+			for range tx.Vin {
+				entry(spent[0].Output.PubKeyHash).Sent += spent[0].Output.Value
+				spent = spent[1:]
+			}
+		}
+
+		for _, out := range tx.Vout {
+			entry(out.PubKeyHash).Received += out.Value
+		}
+
+		for pubKeyHash, at := range entries {
+			err := a.Put(addrIndexKey([]byte(pubKeyHash), block.Height, position), at.Serialize())
+			if err != nil {
+				return counter, err
+			}
+			counter++
+		}
+	}
+
+	return counter, nil
+}
+
+// unindexAddresses removes the transactions of a block disconnected from the
+// main chain from the address index
+func unindexAddresses(a storage.Bucket, block *Block, undo BlockUndo) error {
+	spent := undo.Spent
+
+	for position, tx := range block.Transactions {
+		var pubKeyHashes [][]byte
+
+		if !tx.IsCoinbase() {
+			for range tx.Vin {
+				pubKeyHashes = append(pubKeyHashes, spent[0].Output.PubKeyHash)
+				spent = spent[1:]
+			}
+		}
+
+		for _, out := range tx.Vout {
+			pubKeyHashes = append(pubKeyHashes, out.PubKeyHash)
+		}
+
+		for _, pubKeyHash := range pubKeyHashes {
+			err := a.Delete(addrIndexKey(pubKeyHash, block.Height, position))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReindexAddresses builds the address index from the main chain, enabling it
+// from now on, and returns the number of indexed entries. It needs every
+// block, so ErrPruned is returned on pruned nodes.
+func (bc *Blockchain) ReindexAddresses() (int, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bucketName := []byte(addrIndexBucket)
+	counter := 0
+
+	pruned, err := bc.IsPruned()
+	if err != nil {
+		return 0, err
+	}
+	if pruned {
+		return 0, ErrPruned
+	}
+
+	err = bc.db.Update(func(tx storage.Tx) error {
+		err := tx.DeleteBucket(bucketName)
+		if err != nil && err != storage.ErrBucketNotFound {
+			return err
+		}
+
+		a, err := tx.CreateBucket(bucketName)
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket([]byte(blocksBucket))
+		t := tx.Bucket([]byte(txIndexBucket))
+		h := tx.Bucket([]byte(heightIndexBucket))
+		ud := tx.Bucket([]byte(undoBucket))
+
+		for height := 0; ; height++ {
+			hash := h.Get(utils.IntToHex(int64(height)))
+			if hash == nil {
+				break
+			}
+
+			block, err := DeserializeBlock(b.Get(hash))
+			if err != nil {
+				return err
+			}
+			undo, err := loadBlockUndo(ud, t, b, block)
+			if err != nil {
+				return err
+			}
+			indexed, err := indexAddresses(a, block, undo)
+			if err != nil {
+				return err
+			}
+			counter += indexed
+		}
+
+		return nil
+	})
+
+	return counter, err
+}
+
+// FindAddressTransactions returns the history of the public key hash in chain
+// order, or ErrAddressIndexDisabled when the index hasn't been built
+func (bc *Blockchain) FindAddressTransactions(pubKeyHash []byte) ([]AddressTransaction, error) {
+	var history []AddressTransaction
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		a := tx.Bucket([]byte(addrIndexBucket))
+		if a == nil {
+			return ErrAddressIndexDisabled
+		}
+
+		c := a.Cursor()
+		for k, v := c.Seek(pubKeyHash); k != nil && bytes.HasPrefix(k, pubKeyHash); k, v = c.Next() {
+			// Keys of longer hashes starting with this one sort in between
+			if len(k) != len(addrIndexKey(pubKeyHash, 0, 0)) {
+				continue
+			}
+
+			at, err := DeserializeAddressTransaction(v)
+			if err != nil {
+				return err
+			}
+			history = append(history, at)
+		}
+
+		return nil
+	})
+
+	return history, err
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+// Block represents a block in the blockchain: a header and the transactions
+// its Merkle root commits to
+type Block struct {
+	BlockHeader
+	Hash         []byte // 这个区块的哈希值，即区块头的哈希值
+	Transactions []*transaction.Transaction
+}
+
+// NewBlock creates and returns Block mined for the target given in compact
+// form, stamped with the given Unix time
+func NewBlock(transactions []*transaction.Transaction, prevBlockHash []byte, height int, bits uint32, timestamp int64) *Block {
+	header := BlockHeader{blockVersion, prevBlockHash, nil, timestamp, bits, 0, height}
+	block := &Block{header, []byte{}, transactions}
+	block.MerkleRoot = block.HashTransactions()
+
+	pow := NewProofOfWork(&block.BlockHeader)
+	nonce, hash := pow.Run()
+
+	block.Hash = hash[:]
+	block.Nonce = nonce
+
+	return block
+}
+
+// NewGenesisBlock creates and returns genesis Block, stamped with the
+// network's genesis timestamp
+func NewGenesisBlock(coinbase *transaction.Transaction) *Block {
+	return NewBlock([]*transaction.Transaction{coinbase}, []byte{}, 0, params.Active.PowLimitBits, params.Active.GenesisTimestamp)
+}
+
+// IsPruned checks whether the transactions of the block were pruned. Only the
+// header is left in that case.
+func (b *Block) IsPruned() bool {
+	return len(b.Transactions) == 0
+}
+
+// HashTransactions returns a hash of the transactions in the block
+func (b *Block) HashTransactions() []byte {
+	var transactions [][]byte
+
+	for _, tx := range b.Transactions {
+		transactions = append(transactions, tx.Serialize())
+	}
+	mTree := NewMerkleTree(transactions)
+
+	return mTree.RootNode.Data
+}
+
+// Serialize returns the canonical encoding of the block:
+//
+//	header            the fields of BlockHeader.Serialize
+//	len(Transactions) uvarint
+//	Transactions      for each transaction, its encoding as bytes
+//
+// The hash of the block isn't part of it, it is the hash of the header.
+func (b *Block) Serialize() []byte {
+	var w utils.Writer
+
+	b.BlockHeader.encode(&w)
+	w.Uvarint(uint64(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		w.VarBytes(tx.Serialize())
+	}
+
+	return w.Data()
+}
+
+// DeserializeBlock decodes a block from its canonical encoding
+func DeserializeBlock(d []byte) (*Block, error) {
+	r := utils.NewReader(d)
+	block := Block{BlockHeader: decodeBlockHeader(r)}
+
+	for n := r.Count(); n > 0; n-- {
+		tx, err := transaction.DeserializeTransaction(r.VarBytes())
+		if err != nil {
+			return nil, fmt.Errorf("block: %w", err)
+		}
+		block.Transactions = append(block.Transactions, &tx)
+	}
+
+	if err := r.Finish(); err != nil {
+		return nil, fmt.Errorf("block: %w", err)
+	}
+	block.Hash = block.BlockHeader.Hash()
+
+	return &block, nil
+}
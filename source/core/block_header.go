@@ -0,0 +1,168 @@
+package core
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+// headersBucket maps block hashes to block headers, so the chain can be
+// walked without decoding any transactions
+const headersBucket = "headers"
+
+// blockVersion is the version of the blocks created by this node
+const blockVersion = 1
+
+// BlockHeader holds the fields of a block that its hash commits to. The
+// transactions are committed to through the Merkle root.
+type BlockHeader struct {
+	Version       int
+	PrevBlockHash []byte // 上一个区块的哈希值
+	MerkleRoot    []byte // 交易的默克尔树根
+	Timestamp     int64  // 区块创建的时间
+	Bits          uint32 // 压缩格式的难度目标
+	Nonce         int
+	Height        int
+}
+
+// Hash returns the hash of the header, which is the hash of its block.
+// Headers of blocks converted from gob carry that hash, see
+// legacyBlockVersion.
+func (h *BlockHeader) Hash() []byte {
+	if h.Version == legacyBlockVersion {
+		return append([]byte{}, h.MerkleRoot...)
+	}
+
+	hash := sha256.Sum256(NewProofOfWork(h).prepareData(h.Nonce))
+
+	return hash[:]
+}
+
+// Serialize returns the canonical encoding of the header:
+//
+//	Version       int64
+//	PrevBlockHash bytes
+//	MerkleRoot    bytes
+//	Timestamp     int64
+//	Bits          uint32
+//	Nonce         int64
+//	Height        int64
+//
+// The hash of the header is taken over the proof-of-work data instead, see
+// ProofOfWork.
+func (h *BlockHeader) Serialize() []byte {
+	var w utils.Writer
+	h.encode(&w)
+
+	return w.Data()
+}
+
+func (h *BlockHeader) encode(w *utils.Writer) {
+	w.Int64(int64(h.Version))
+	w.VarBytes(h.PrevBlockHash)
+	w.VarBytes(h.MerkleRoot)
+	w.Int64(h.Timestamp)
+	w.Uint32(h.Bits)
+	w.Int64(int64(h.Nonce))
+	w.Int64(int64(h.Height))
+}
+
+func decodeBlockHeader(r *utils.Reader) BlockHeader {
+	return BlockHeader{
+		Version:       int(r.Int64()),
+		PrevBlockHash: r.VarBytes(),
+		MerkleRoot:    r.VarBytes(),
+		Timestamp:     r.Int64(),
+		Bits:          r.Uint32(),
+		Nonce:         int(r.Int64()),
+		Height:        int(r.Int64()),
+	}
+}
+
+// DeserializeBlockHeader decodes a header from its canonical encoding
+func DeserializeBlockHeader(d []byte) (*BlockHeader, error) {
+	r := utils.NewReader(d)
+	header := decodeBlockHeader(r)
+	if err := r.Finish(); err != nil {
+		return nil, fmt.Errorf("block header: %w", err)
+	}
+
+	return &header, nil
+}
+
+// GetBlockHeader finds a block header by the block hash and returns it
+func (bc *Blockchain) GetBlockHeader(blockHash []byte) (BlockHeader, error) {
+	var header BlockHeader
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		h := tx.Bucket([]byte(headersBucket))
+
+		headerData := h.Get(blockHash)
+		if headerData == nil {
+			return ErrBlockNotFound
+		}
+
+		decoded, err := DeserializeBlockHeader(headerData)
+		if err != nil {
+			return err
+		}
+		header = *decoded
+
+		return nil
+	})
+
+	return header, err
+}
+
+// reindexHeaders rebuilds the headers bucket from the blocks bucket
+func (bc *Blockchain) reindexHeaders() error {
+	return bc.db.Update(indexHeaders)
+}
+
+// indexHeaders replaces the headers bucket with the headers of the blocks in
+// the blocks bucket, on every branch
+func indexHeaders(tx storage.Tx) error {
+	bucketName := []byte(headersBucket)
+	err := tx.DeleteBucket(bucketName)
+	if err != nil && err != storage.ErrBucketNotFound {
+		return err
+	}
+
+	h, err := tx.CreateBucket(bucketName)
+	if err != nil {
+		return err
+	}
+
+	// Collect the blocks first, cursors don't survive writes
+	var blocks [][]byte
+	c := tx.Bucket([]byte(blocksBucket)).Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if string(k) != "l" {
+			blocks = append(blocks, append([]byte{}, v...))
+		}
+	}
+
+	for _, data := range blocks {
+		block, err := DeserializeBlock(data)
+		if err != nil {
+			return err
+		}
+		if err := h.Put(block.Hash, block.BlockHeader.Serialize()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getParentHeader returns the header of the block preceding the given one
+func (bc *Blockchain) getParentHeader(header *BlockHeader) (*BlockHeader, error) {
+	parent, err := bc.GetBlockHeader(header.PrevBlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parent, nil
+}
@@ -0,0 +1,960 @@
+// Package core implements the blockchain: blocks, proof-of-work, consensus
+// rules, the UTXO set and the indexes kept next to the chain.
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+const blocksBucket = "blocks"
+const chainWorkBucket = "chainwork"
+
+// ErrBlockchainExists is returned when creating a blockchain DB that already
+// exists
+var ErrBlockchainExists = errors.New("blockchain already exists")
+
+// ErrBlockchainNotFound is returned when opening a blockchain DB that hasn't
+// been created
+var ErrBlockchainNotFound = errors.New("no existing blockchain found, create one first")
+
+// ErrBlockNotFound is returned when a block is neither in the main chain nor
+// in a side branch
+var ErrBlockNotFound = errors.New("block is not found")
+
+// ErrTransactionNotFound is returned when a transaction isn't in the main
+// chain, or its block was pruned
+var ErrTransactionNotFound = errors.New("transaction is not found")
+
+// Blockchain implements interactions with a DB. It is safe for concurrent
+// use: changes to the chain are serialized, and reads see the chain as left
+// by the last committed change. Main chain changes are published on its
+// Notifier.
+type Blockchain struct {
+	// mu serializes the methods changing the chain. They must not call each
+	// other.
+	mu sync.Mutex
+
+	// tipMu guards tip. Changes hold mu as well, so they can read tip
+	// without it.
+	tipMu sync.RWMutex
+	tip   []byte
+
+	db       storage.Storage
+	notifier Notifier
+	// times holds the clock offsets of the peers of the node
+	times timeData
+}
+
+// CreateBlockchain creates a new blockchain DB holding the genesis block of
+// the network
+func CreateBlockchain(nodeID string) (*Blockchain, error) {
+	dbFile := fmt.Sprintf(params.Active.DBFile, nodeID)
+	if dbExists(dbFile) {
+		return nil, fmt.Errorf("%w: %s", ErrBlockchainExists, dbFile)
+	}
+
+	return openBlockchainFile(dbFile)
+}
+
+// NewBlockchain opens the existing blockchain DB of the node. A DB holding
+// another network's chain is refused.
+func NewBlockchain(nodeID string) (*Blockchain, error) {
+	dbFile := fmt.Sprintf(params.Active.DBFile, nodeID)
+	if dbExists(dbFile) == false {
+		return nil, fmt.Errorf("%w: %s", ErrBlockchainNotFound, dbFile)
+	}
+
+	return openBlockchainFile(dbFile)
+}
+
+// openBlockchainFile opens the blockchain kept in a bolt DB file
+func openBlockchainFile(dbFile string) (*Blockchain, error) {
+	db, err := storage.OpenBolt(dbFile)
+	if err != nil {
+		return nil, err
+	}
+
+	bc, err := OpenBlockchain(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s can't be used on %s: %w", dbFile, params.Active.Name, err)
+	}
+
+	return bc, nil
+}
+
+// OpenBlockchain opens the blockchain kept in the storage. Empty storage is
+// initialized with the genesis block of the network. The chain must start
+// with that genesis block.
+func OpenBlockchain(db storage.Storage) (*Blockchain, error) {
+	var tip []byte
+	var missingHeaders, missingTxIndex, missingHeightIndex bool
+
+	err := db.Update(func(tx storage.Tx) error {
+		if tx.Bucket([]byte(blocksBucket)) == nil {
+			return storeGenesis(tx)
+		}
+		if isGobFormat(tx) {
+			fmt.Println("Converting the blockchain DB from gob to the binary encoding")
+			return migrateFromGob(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		tip = append([]byte{}, b.Get([]byte("l"))...)
+
+		for _, name := range []string{chainWorkBucket, undoBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		missingHeaders = tx.Bucket([]byte(headersBucket)) == nil
+		missingTxIndex = tx.Bucket([]byte(txIndexBucket)) == nil
+		missingHeightIndex = tx.Bucket([]byte(heightIndexBucket)) == nil
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bc := Blockchain{tip: tip, db: db}
+
+	// Databases created before the headers and the indexes were kept apart
+	// from the blocks get them built once
+	if missingHeaders {
+		if err := bc.reindexHeaders(); err != nil {
+			return nil, err
+		}
+	}
+	if missingTxIndex {
+		if _, err := bc.ReindexTransactions(); err != nil {
+			return nil, err
+		}
+	}
+	if missingHeightIndex {
+		if err := bc.reindexHeights(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := bc.checkGenesis(); err != nil {
+		return nil, err
+	}
+
+	return &bc, nil
+}
+
+// storeGenesis creates the buckets of a new chain and stores the genesis
+// block of the network in them, with its outputs in the UTXO set
+func storeGenesis(tx storage.Tx) error {
+	genesis, err := GenesisBlock()
+	if err != nil {
+		return err
+	}
+
+	buckets := make(map[string]storage.Bucket)
+	for _, name := range []string{blocksBucket, headersBucket, chainWorkBucket, txIndexBucket, heightIndexBucket, undoBucket, utxoBucket} {
+		b, err := tx.CreateBucket([]byte(name))
+		if err != nil {
+			return err
+		}
+		buckets[name] = b
+	}
+
+	if err := writeFormat(tx); err != nil {
+		return err
+	}
+
+	b := buckets[blocksBucket]
+	if err := b.Put(genesis.Hash, genesis.Serialize()); err != nil {
+		return err
+	}
+	if err := b.Put([]byte("l"), genesis.Hash); err != nil {
+		return err
+	}
+
+	if err := buckets[headersBucket].Put(genesis.Hash, genesis.BlockHeader.Serialize()); err != nil {
+		return err
+	}
+
+	if err := indexTransactions(buckets[txIndexBucket], genesis); err != nil {
+		return err
+	}
+	if err := indexHeight(buckets[heightIndexBucket], genesis); err != nil {
+		return err
+	}
+
+	return connectGenesis(buckets[utxoBucket], genesis)
+}
+
+// connectGenesis adds the outputs of the genesis coinbase to the UTXO bucket.
+// Unlike connectBlock it doesn't hold them to the block subsidy, the premine
+// may exceed it.
+func connectGenesis(b storage.Bucket, genesis *Block) error {
+	return addOutputs(b, genesis, genesis.Transactions[0])
+}
+
+// AddBlock validates the block and saves it into the blockchain. When the
+// block's branch has more cumulative proof-of-work than the main chain, the
+// chain is reorganized onto it; on equal work the branch whose tip has the
+// lower hash wins. The blocks removed from and added to the main chain are
+// returned. A *BlockError is returned when the block, or a block of its
+// branch, breaks a consensus rule; such blocks are not kept.
+func (bc *Blockchain) AddBlock(block *Block) (disconnected, connected []*Block, err error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if _, err := bc.GetBlockHeader(block.Hash); err == nil {
+		return nil, nil, nil
+	}
+
+	if err := checkBlock(block); err != nil {
+		return nil, nil, err
+	}
+
+	if err := bc.checkBlockContext(block); err != nil {
+		return nil, nil, err
+	}
+
+	err = bc.db.Update(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		h := tx.Bucket([]byte(headersBucket))
+
+		err := h.Put(block.Hash, block.BlockHeader.Serialize())
+		if err != nil {
+			return err
+		}
+
+		return b.Put(block.Hash, block.Serialize())
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	work, err := bc.chainWork(&block.BlockHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tip, err := bc.GetBlockHeader(bc.tip)
+	if err != nil {
+		return nil, nil, err
+	}
+	tipWork, err := bc.chainWork(&tip)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch work.Cmp(tipWork) {
+	case -1:
+		return nil, nil, nil
+	case 0:
+		if bytes.Compare(block.Hash, bc.tip) >= 0 {
+			return nil, nil, nil
+		}
+	}
+
+	return bc.reorganize(block)
+}
+
+// chainWork returns the cumulative proof-of-work of the chain ending with the
+// block of the header. Values missing from the chainwork bucket, e.g. in a
+// database created before it existed, are computed from the nearest known
+// ancestor and saved.
+func (bc *Blockchain) chainWork(header *BlockHeader) (*big.Int, error) {
+	var work *big.Int
+	var pending []*BlockHeader
+
+	for work == nil {
+		err := bc.db.View(func(tx storage.Tx) error {
+			b := tx.Bucket([]byte(chainWorkBucket))
+			if workData := b.Get(header.Hash()); workData != nil {
+				work = new(big.Int).SetBytes(workData)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if work != nil {
+			break
+		}
+
+		pending = append(pending, header)
+		if len(header.PrevBlockHash) == 0 {
+			work = big.NewInt(0)
+			break
+		}
+
+		parent, err := bc.getParentHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		header = parent
+	}
+
+	if len(pending) == 0 {
+		return work, nil
+	}
+
+	err := bc.db.Update(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(chainWorkBucket))
+
+		for i := len(pending) - 1; i >= 0; i-- {
+			work = new(big.Int).Add(work, NewProofOfWork(pending[i]).Work())
+
+			err := b.Put(pending[i].Hash(), work.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return work, nil
+}
+
+// reorganize makes newTip the tip of the main chain. The common ancestor is
+// found by walking the headers of both branches, then blocks of the current
+// branch are disconnected back to it and blocks of the new branch are
+// connected, updating the UTXO set and the indexes in a single DB
+// transaction.
+// When a block of the new branch fails validation, nothing is changed and
+// that block and its descendants are removed from the DB.
+func (bc *Blockchain) reorganize(newTip *Block) (disconnected, connected []*Block, err error) {
+	var oldHashes, newHashes [][]byte
+
+	oldTip, err := bc.GetBlockHeader(bc.tip)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oldBranch := &oldTip
+	newBranch := &newTip.BlockHeader
+
+	for oldBranch.Height > newBranch.Height {
+		oldHashes = append(oldHashes, oldBranch.Hash())
+		if oldBranch, err = bc.getParentHeader(oldBranch); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for newBranch.Height > oldBranch.Height {
+		newHashes = append(newHashes, newBranch.Hash())
+		if newBranch, err = bc.getParentHeader(newBranch); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for bytes.Compare(oldBranch.Hash(), newBranch.Hash()) != 0 {
+		oldHashes = append(oldHashes, oldBranch.Hash())
+		newHashes = append(newHashes, newBranch.Hash())
+
+		if newBranch, err = bc.getParentHeader(newBranch); err != nil {
+			return nil, nil, err
+		}
+		if oldBranch, err = bc.getParentHeader(oldBranch); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if disconnected, err = bc.getBlocks(oldHashes); err != nil {
+		return nil, nil, err
+	}
+	if connected, err = bc.getBlocks(newHashes); err != nil {
+		return nil, nil, err
+	}
+
+	for _, block := range disconnected {
+		if block.IsPruned() {
+			return nil, nil, ErrPrunedReorganize
+		}
+	}
+
+	// connected was collected from the tip down, but must be applied upwards
+	for i, j := 0, len(connected)-1; i < j; i, j = i+1, j-1 {
+		connected[i], connected[j] = connected[j], connected[i]
+	}
+
+	err = bc.db.Update(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+
+		for _, block := range disconnected {
+			if err := disconnectFromMainChain(tx, block); err != nil {
+				return err
+			}
+		}
+
+		for _, block := range connected {
+			if err := connectToMainChain(tx, block); err != nil {
+				return err
+			}
+		}
+
+		return b.Put([]byte("l"), newTip.Hash)
+	})
+	if blockErr, ok := err.(*BlockError); ok {
+		for i, block := range connected {
+			if bytes.Compare(block.Hash, blockErr.Hash) == 0 {
+				if err := bc.removeBlocks(connected[i:]); err != nil {
+					return nil, nil, err
+				}
+				break
+			}
+		}
+
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	bc.setTip(newTip.Hash)
+	bc.notifyChainChange(disconnected, connected, newTip.Hash, newTip.Height)
+
+	return disconnected, connected, nil
+}
+
+// connectToMainChain applies a block extending the main chain to the UTXO set
+// and the indexes, and stores its undo data
+func connectToMainChain(tx storage.Tx, block *Block) error {
+	t := tx.Bucket([]byte(txIndexBucket))
+
+	var undo BlockUndo
+	var err error
+	if isMigrated(tx, block.Hash) {
+		undo, err = connectLegacyBlock(tx.Bucket([]byte(utxoBucket)), block)
+	} else {
+		undo, err = connectBlock(tx.Bucket([]byte(utxoBucket)), block, checksSignatures(tx, block))
+	}
+	if err != nil {
+		return err
+	}
+
+	err = tx.Bucket([]byte(undoBucket)).Put(block.Hash, undo.Serialize())
+	if err != nil {
+		return err
+	}
+
+	if err := indexTransactions(t, block); err != nil {
+		return err
+	}
+	if err := indexHeight(tx.Bucket([]byte(heightIndexBucket)), block); err != nil {
+		return err
+	}
+	if a := tx.Bucket([]byte(addrIndexBucket)); a != nil {
+		if _, err := indexAddresses(a, block, undo); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// disconnectFromMainChain reverts connectToMainChain for the tip of the main
+// chain
+func disconnectFromMainChain(tx storage.Tx, block *Block) error {
+	b := tx.Bucket([]byte(blocksBucket))
+	t := tx.Bucket([]byte(txIndexBucket))
+	ud := tx.Bucket([]byte(undoBucket))
+
+	undo, err := loadBlockUndo(ud, t, b, block)
+	if err != nil {
+		return err
+	}
+	if err := disconnectBlock(tx.Bucket([]byte(utxoBucket)), block, undo); err != nil {
+		return err
+	}
+
+	if err := ud.Delete(block.Hash); err != nil {
+		return err
+	}
+
+	if err := unindexTransactions(t, block); err != nil {
+		return err
+	}
+	if err := unindexHeight(tx.Bucket([]byte(heightIndexBucket)), block); err != nil {
+		return err
+	}
+	if a := tx.Bucket([]byte(addrIndexBucket)); a != nil {
+		return unindexAddresses(a, block, undo)
+	}
+
+	return nil
+}
+
+// removeBlocks deletes blocks that are not part of the main chain
+func (bc *Blockchain) removeBlocks(blocks []*Block) error {
+	return bc.db.Update(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		h := tx.Bucket([]byte(headersBucket))
+		w := tx.Bucket([]byte(chainWorkBucket))
+
+		for _, block := range blocks {
+			err := b.Delete(block.Hash)
+			if err != nil {
+				return err
+			}
+
+			err = h.Delete(block.Hash)
+			if err != nil {
+				return err
+			}
+
+			err = w.Delete(block.Hash)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// RollbackTo disconnects main chain blocks until the tip is at the given
+// height, restoring the UTXO set and the indexes from undo data. The
+// disconnected blocks are removed, so they will be downloaded and validated
+// again if the network still builds on them. They are returned tip first.
+// ErrPruned is returned when a block to disconnect was pruned.
+func (bc *Blockchain) RollbackTo(height int) ([]*Block, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	var disconnected []*Block
+	var newTip []byte
+	var newHeight int
+
+	err := bc.db.Update(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		block, err := DeserializeBlock(b.Get(bc.tip))
+		if err != nil {
+			return err
+		}
+
+		for block.Height > height {
+			if block.IsPruned() {
+				return ErrPruned
+			}
+
+			if err := disconnectFromMainChain(tx, block); err != nil {
+				return err
+			}
+			disconnected = append(disconnected, block)
+
+			if block, err = DeserializeBlock(b.Get(block.PrevBlockHash)); err != nil {
+				return err
+			}
+		}
+
+		newTip, newHeight = block.Hash, block.Height
+
+		return b.Put([]byte("l"), block.Hash)
+	})
+	if err != nil {
+		return nil, err
+	}
+	bc.setTip(newTip)
+	if len(disconnected) > 0 {
+		bc.notifyChainChange(disconnected, nil, newTip, newHeight)
+	}
+
+	return disconnected, bc.removeBlocks(disconnected)
+}
+
+// getBlocks loads the blocks with the given hashes
+func (bc *Blockchain) getBlocks(hashes [][]byte) ([]*Block, error) {
+	blocks := make([]*Block, len(hashes))
+
+	for i, hash := range hashes {
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+		blocks[i] = &block
+	}
+
+	return blocks, nil
+}
+
+// FindTransaction finds a main chain found by its ID
+func (bc *Blockchain) FindTransaction(ID []byte) (transaction.Transaction, error) {
+	var found transaction.Transaction
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		t := tx.Bucket([]byte(txIndexBucket))
+		b := tx.Bucket([]byte(blocksBucket))
+
+		var err error
+		found, err = lookupTransaction(t, b, ID)
+
+		return err
+	})
+
+	return found, err
+}
+
+// FindUTXO finds all unspent transaction outputs and returns transactions with
+// spent outputs removed. It needs every block, so ErrPruned is returned on
+// pruned nodes.
+func (bc *Blockchain) FindUTXO() (map[string]transaction.TXOutputs, error) {
+	pruned, err := bc.IsPruned()
+	if err != nil {
+		return nil, err
+	}
+	if pruned {
+		return nil, ErrPruned
+	}
+
+	UTXO := make(map[string]transaction.TXOutputs)
+	spentTXOs := make(map[string][]int)
+	bci := bc.Iterator()
+
+	for {
+		header, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
+		block, err := bc.GetBlock(header.Hash())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range block.Transactions {
+			txID := hex.EncodeToString(tx.ID)
+
+		Outputs:
+			for outIdx := range tx.Vout {
+				// Was the output spent?
+				if spentTXOs[txID] != nil {
+					for _, spentOutIdx := range spentTXOs[txID] {
+						if spentOutIdx == outIdx {
+							continue Outputs
+						}
+					}
+				}
+
+				outs := UTXO[txID]
+				if outs.Outputs == nil {
+					outs = transaction.TXOutputs{Outputs: make(map[int]transaction.TXOutput), Height: block.Height, Coinbase: tx.IsCoinbase()}
+				}
+				outs.Outputs[outIdx] = tx.Vout[outIdx]
+				UTXO[txID] = outs
+			}
+
+			if tx.IsCoinbase() == false {
+				for _, in := range tx.Vin {
+					inTxID := hex.EncodeToString(in.Txid)
+					spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Vout)
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return UTXO, nil
+}
+
+// Tip returns the hash of the last block of the main chain
+func (bc *Blockchain) Tip() []byte {
+	bc.tipMu.RLock()
+	defer bc.tipMu.RUnlock()
+
+	return bc.tip
+}
+
+// setTip makes the block with the hash the tip of the main chain. The caller
+// holds mu and has already stored the new tip in the DB.
+func (bc *Blockchain) setTip(hash []byte) {
+	bc.tipMu.Lock()
+	defer bc.tipMu.Unlock()
+
+	bc.tip = hash
+}
+
+// Close closes the DB of the blockchain
+func (bc *Blockchain) Close() error {
+	return bc.db.Close()
+}
+
+// Iterator returns a BlockchainIterat
+func (bc *Blockchain) Iterator() *BlockchainIterator {
+	bci := &BlockchainIterator{bc.Tip(), bc.db}
+
+	return bci
+}
+
+// GetBestHeight returns the height of the latest block
+func (bc *Blockchain) GetBestHeight() (int, error) {
+	lastHeader, err := bc.tipHeader()
+	if err != nil {
+		return 0, err
+	}
+
+	return lastHeader.Height, nil
+}
+
+// tipHeader returns the header of the main chain tip
+func (bc *Blockchain) tipHeader() (*BlockHeader, error) {
+	var lastHeader *BlockHeader
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		h := tx.Bucket([]byte(headersBucket))
+		lastHash := b.Get([]byte("l"))
+
+		var err error
+		lastHeader, err = DeserializeBlockHeader(h.Get(lastHash))
+
+		return err
+	})
+
+	return lastHeader, err
+}
+
+// GetBlock finds a block by its hash and returns it
+func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
+	var block Block
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+
+		blockData := b.Get(blockHash)
+
+		if blockData == nil {
+			return ErrBlockNotFound
+		}
+
+		decoded, err := DeserializeBlock(blockData)
+		if err != nil {
+			return err
+		}
+		block = *decoded
+
+		return nil
+	})
+	if err != nil {
+		return block, err
+	}
+
+	return block, nil
+}
+
+// GetBlockHashes returns a list of hashes of all the blocks in the chain
+func (bc *Blockchain) GetBlockHashes() ([][]byte, error) {
+	var blocks [][]byte
+	bci := bc.Iterator()
+
+	for {
+		header, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, header.Hash())
+
+		if len(header.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return blocks, nil
+}
+
+// MineBlock mines a new block with the provided transactions. A coinbase
+// paying the block subsidy plus the fees of the transactions to minerAddress
+// is added in front of them. The error of the first invalid transaction is
+// returned without mining anything.
+func (bc *Blockchain) MineBlock(minerAddress string, transactions []*transaction.Transaction) (*Block, error) {
+	for _, tx := range transactions {
+		if err := bc.VerifyTransaction(tx); err != nil {
+			return nil, fmt.Errorf("transaction %x: %w", tx.ID, err)
+		}
+	}
+
+	lastHeader, err := bc.tipHeader()
+	if err != nil {
+		return nil, err
+	}
+	lastHash := lastHeader.Hash()
+
+	fees := 0
+	UTXOSet := UTXOSet{bc}
+	for _, tx := range transactions {
+		fee, err := UTXOSet.Fee(tx)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if fees, ok = addMoney(fees, fee); !ok {
+			return nil, fmt.Errorf("%w: the fees of the block", ErrBadAmount)
+		}
+	}
+
+	bits, err := bc.nextBits(lastHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	height := lastHeader.Height + 1
+	reward, ok := addMoney(blockSubsidy(height), fees)
+	if !ok {
+		return nil, fmt.Errorf("%w: the block reward", ErrBadAmount)
+	}
+	cbTx, err := transaction.NewCoinbaseTX(minerAddress, "", reward)
+	if err != nil {
+		return nil, err
+	}
+	transactions = append([]*transaction.Transaction{cbTx}, transactions...)
+
+	newBlock := NewBlock(transactions, lastHash, height, bits, bc.nextTimestamp(lastHeader))
+	_, _, err = bc.AddBlock(newBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlock, nil
+}
+
+// nextBits returns the compact target required for the block following
+// parent. It changes only at every RetargetInterval-th height, based on how
+// long the last interval of parent's branch took to mine.
+func (bc *Blockchain) nextBits(parent *BlockHeader) (uint32, error) {
+	if (parent.Height+1)%params.Active.RetargetInterval != 0 {
+		return parent.Bits, nil
+	}
+
+	first := parent
+	for i := 0; i < params.Active.RetargetInterval-1; i++ {
+		var err error
+		if first, err = bc.getParentHeader(first); err != nil {
+			return 0, err
+		}
+	}
+
+	return retarget(parent.Bits, parent.Timestamp-first.Timestamp), nil
+}
+
+// SignTransaction signs inputs of a Transaction
+func (bc *Blockchain) SignTransaction(tx *transaction.Transaction, privKey ecdsa.PrivateKey) error {
+	prevTXs, err := bc.prevTransactions(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Sign(privKey, prevTXs)
+}
+
+// VerifyTransaction verifies transaction input signatures. Transactions
+// spending outputs that are not in the UTXO set get ErrMissingInput, and
+// those spending coinbase outputs that won't be mature in the next block get
+// ErrImmatureSpend.
+func (bc *Blockchain) VerifyTransaction(tx *transaction.Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	prevTXs, err := bc.prevTransactions(tx)
+	if err != nil {
+		return err
+	}
+
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	if err := bc.checkInputsMature(tx, bestHeight+1); err != nil {
+		return err
+	}
+
+	return tx.Verify(prevTXs)
+}
+
+// prevTransactions returns the transactions spent by the inputs of tx, as far
+// as their outputs are still unspent. The UTXO set is used rather than the
+// transaction index, so this also works for outputs of pruned blocks.
+func (bc *Blockchain) prevTransactions(tx *transaction.Transaction) (map[string]transaction.Transaction, error) {
+	prevTXs := make(map[string]transaction.Transaction)
+
+	err := bc.db.View(func(dbTx storage.Tx) error {
+		b := dbTx.Bucket([]byte(utxoBucket))
+
+		for _, vin := range tx.Vin {
+			outsBytes := b.Get(vin.Txid)
+			if outsBytes == nil {
+				return fmt.Errorf("%w: %x:%d", transaction.ErrMissingInput, vin.Txid, vin.Vout)
+			}
+
+			outs, err := transaction.DeserializeOutputs(outsBytes)
+			if err != nil {
+				return err
+			}
+			if _, ok := outs.Outputs[vin.Vout]; !ok {
+				return fmt.Errorf("%w: %x:%d", transaction.ErrMissingInput, vin.Txid, vin.Vout)
+			}
+			prevTXs[hex.EncodeToString(vin.Txid)] = unspentTransaction(vin.Txid, outs)
+		}
+
+		return nil
+	})
+
+	return prevTXs, err
+}
+
+// checkInputsMature checks that every output spent by tx can be spent in a
+// block at the given height
+func (bc *Blockchain) checkInputsMature(tx *transaction.Transaction, spendHeight int) error {
+	return bc.db.View(func(dbTx storage.Tx) error {
+		b := dbTx.Bucket([]byte(utxoBucket))
+
+		for _, vin := range tx.Vin {
+			outsBytes := b.Get(vin.Txid)
+			if outsBytes == nil {
+				continue
+			}
+
+			outs, err := transaction.DeserializeOutputs(outsBytes)
+			if err != nil {
+				return err
+			}
+			if !outs.IsMature(spendHeight) {
+				return fmt.Errorf("%w: %x:%d", ErrImmatureSpend, vin.Txid, vin.Vout)
+			}
+		}
+
+		return nil
+	})
+}
+
+func dbExists(dbFile string) bool {
+	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
+		return false
+	}
+
+	return true
+}
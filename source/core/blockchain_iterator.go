@@ -0,0 +1,36 @@
+package core
+
+import (
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+)
+
+// BlockchainIterator is used to iterate over the block headers of the chain
+type BlockchainIterator struct {
+	currentHash []byte
+	db          storage.Storage
+}
+
+// Next returns the header of the next block starting from the tip
+func (i *BlockchainIterator) Next() (*BlockHeader, error) {
+	var header *BlockHeader
+
+	err := i.db.View(func(tx storage.Tx) error {
+		h := tx.Bucket([]byte(headersBucket))
+		encodedHeader := h.Get(i.currentHash)
+		if encodedHeader == nil {
+			return ErrBlockNotFound
+		}
+
+		var err error
+		header, err = DeserializeBlockHeader(encodedHeader)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	i.currentHash = header.PrevBlockHash
+
+	return header, nil
+}
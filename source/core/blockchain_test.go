@@ -0,0 +1,782 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+// newTestBlockchain creates a regtest blockchain in memory and
+// returns it together with the wallet the genesis block premines to.
+// Coinbase outputs are spendable at once. The test may change params freely.
+func newTestBlockchain(t *testing.T) (*Blockchain, *wallet.Wallet) {
+	saved := params.Active
+	testParams := params.RegTest
+	testParams.CoinbaseMaturity = 0
+	params.Active = &testParams
+
+	w := newWallet(t)
+	premine := []byte(fmt.Sprintf(`[{"Address": "%s", "Amount": %d}]`, w.GetAddress(), params.Active.InitialSubsidy))
+	testParams.PremineFile = filepath.Join(t.TempDir(), "premine.json")
+	testParams.GenesisHash = ""
+	testParams.Checkpoints = nil
+	if err := ioutil.WriteFile(testParams.PremineFile, premine, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bc, err := OpenBlockchain(storage.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		bc.db.Close()
+		params.Active = saved
+	})
+
+	return bc, w
+}
+
+// blockTime returns a timestamp for a test block at the given height that
+// stays after the median time past of chains built in the same second
+func blockTime(height int) int64 {
+	return time.Now().Unix() + int64(height)
+}
+
+func balance(t *testing.T, bc *Blockchain, address string) int {
+	pubKeyHash := wallet.Base58Decode([]byte(address))
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+
+	outs, err := UTXOSet{bc}.FindUTXO(pubKeyHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for _, out := range outs {
+		total += out.Value
+	}
+
+	return total
+}
+
+func bestHeight(t *testing.T, bc *Blockchain) int {
+	height, err := bc.GetBestHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return height
+}
+
+func mineBlock(t *testing.T, bc *Blockchain, address string, transactions []*transaction.Transaction) *Block {
+	block, err := bc.MineBlock(address, transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return block
+}
+
+func newTransaction(t *testing.T, bc *Blockchain, w *wallet.Wallet, to string, amount, fee int) *transaction.Transaction {
+	tx, err := NewUTXOTransaction(w, to, amount, fee, &UTXOSet{bc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func newWallet(t *testing.T) *wallet.Wallet {
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func newCoinbaseTX(t *testing.T, to, data string, value int) *transaction.Transaction {
+	tx, err := transaction.NewCoinbaseTX(to, data, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func TestNewBlockchainNeedsCreatedDB(t *testing.T) {
+	newTestBlockchain(t)
+	params.Active.DBFile = filepath.Join(t.TempDir(), "blockchain_%s.db")
+
+	_, err := NewBlockchain("1")
+	assert.True(t, errors.Is(err, ErrBlockchainNotFound))
+	_, err = NewBlockchain("1")
+	assert.True(t, errors.Is(err, ErrBlockchainNotFound), "opening doesn't create the DB")
+
+	bc, err := CreateBlockchain("1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	tip := bc.Tip()
+	assert.NoError(t, bc.Close())
+
+	_, err = CreateBlockchain("1")
+	assert.True(t, errors.Is(err, ErrBlockchainExists))
+
+	bc, err = NewBlockchain("1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, tip, bc.Tip())
+		assert.NoError(t, bc.Close())
+	}
+}
+
+func TestAddBlockReorganize(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	minerA := string(w.GetAddress())
+	minerB := string(newWallet(t).GetAddress())
+	genesis := bc.tip
+
+	a1 := mineBlock(t, bc, minerA, nil)
+	assert.Equal(t, a1.Hash, bc.tip)
+	assert.Equal(t, 2*params.Active.InitialSubsidy, balance(t, bc, minerA))
+
+	b1 := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, minerB, "", params.Active.InitialSubsidy)}, genesis, 1, params.Active.PowLimitBits, blockTime(1))
+	disconnected, connected, err := bc.AddBlock(b1)
+	assert.NoError(t, err)
+	if bytes.Compare(b1.Hash, a1.Hash) < 0 {
+		assert.Len(t, disconnected, 1, "on equal work the lower hash wins")
+		assert.Len(t, connected, 1)
+		assert.Equal(t, b1.Hash, bc.tip)
+	} else {
+		assert.Empty(t, disconnected, "on equal work the lower hash wins")
+		assert.Empty(t, connected)
+		assert.Equal(t, a1.Hash, bc.tip)
+	}
+
+	b2 := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, minerB, "", params.Active.InitialSubsidy)}, b1.Hash, 2, params.Active.PowLimitBits, blockTime(2))
+	_, _, err = bc.AddBlock(b2)
+	assert.NoError(t, err)
+
+	assert.Equal(t, b2.Hash, bc.tip)
+	assert.Equal(t, 2, bestHeight(t, bc))
+	assert.Equal(t, params.Active.InitialSubsidy, balance(t, bc, minerA))
+	assert.Equal(t, 2*params.Active.InitialSubsidy, balance(t, bc, minerB))
+}
+
+func TestMineBlockWithTransfer(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	from := string(w.GetAddress())
+	to := string(newWallet(t).GetAddress())
+	miner := string(newWallet(t).GetAddress())
+
+	tx := newTransaction(t, bc, w, to, 3, 2)
+	fee, err := UTXOSet{bc}.Fee(tx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fee)
+	mineBlock(t, bc, miner, []*transaction.Transaction{tx})
+
+	assert.Equal(t, params.Active.InitialSubsidy-3-2, balance(t, bc, from))
+	assert.Equal(t, 3, balance(t, bc, to))
+	assert.Equal(t, params.Active.InitialSubsidy+2, balance(t, bc, miner), "the miner collects subsidy and fees")
+}
+
+func TestSendErrors(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	to := string(newWallet(t).GetAddress())
+
+	_, err := NewUTXOTransaction(w, to, params.Active.InitialSubsidy, 1, &UTXOSet{bc})
+	assert.True(t, errors.Is(err, ErrInsufficientFunds))
+
+	tx := newTransaction(t, bc, w, to, 1, 0)
+	tx.Vin[0].Signature[0]++
+	assert.True(t, errors.Is(bc.VerifyTransaction(tx), transaction.ErrInvalidSignature))
+
+	_, err = bc.MineBlock(to, []*transaction.Transaction{tx})
+	assert.True(t, errors.Is(err, transaction.ErrInvalidSignature), "invalid transactions are not mined")
+	assert.Equal(t, 0, bestHeight(t, bc))
+
+	tx.Vin[0].Txid = []byte("unknown")
+	assert.True(t, errors.Is(bc.VerifyTransaction(tx), transaction.ErrMissingInput))
+
+	_, err = DeserializeBlock([]byte("malformed"))
+	assert.Error(t, err)
+	_, err = transaction.DeserializeTransaction([]byte("malformed"))
+	assert.Error(t, err)
+}
+
+func TestAddBlockOrphan(t *testing.T) {
+	bc, _ := newTestBlockchain(t)
+	tip := bc.tip
+
+	orphan := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, string(newWallet(t).GetAddress()), "", params.Active.InitialSubsidy)}, []byte("unknown parent"), 5, params.Active.PowLimitBits, blockTime(5))
+	_, _, err := bc.AddBlock(orphan)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectOrphan, err.(*BlockError).Reason)
+	}
+	assert.Equal(t, tip, bc.tip)
+}
+
+func TestAddBlockRejectsStolenOutput(t *testing.T) {
+	bc, _ := newTestBlockchain(t)
+	tip := bc.tip
+	genesis, _ := bc.GetBlock(tip)
+	coinbase := genesis.Transactions[0]
+
+	thief := newWallet(t)
+	stolen := transaction.Transaction{
+		Vin:  []transaction.TXInput{{Txid: coinbase.ID, Vout: 0, PubKey: thief.PublicKey}},
+		Vout: []transaction.TXOutput{*transaction.NewTXOutput(params.Active.InitialSubsidy, string(thief.GetAddress()))},
+	}
+	stolen.ID = stolen.Hash()
+	assert.NoError(t, stolen.Sign(thief.PrivateKey, map[string]transaction.Transaction{hex.EncodeToString(coinbase.ID): *coinbase}))
+
+	block := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, string(thief.GetAddress()), "", params.Active.InitialSubsidy), &stolen}, tip, 1, params.Active.PowLimitBits, blockTime(1))
+	_, _, err := bc.AddBlock(block)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectInvalidSignature, err.(*BlockError).Reason)
+	}
+	assert.Equal(t, tip, bc.tip)
+
+	_, err = bc.GetBlock(block.Hash)
+	assert.Error(t, err, "rejected blocks are not kept")
+}
+
+func TestAddBlockRejectsOverflowingOutputs(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	tip := bc.tip
+	genesis, _ := bc.GetBlock(tip)
+	coinbase := genesis.Transactions[0]
+	address := string(w.GetAddress())
+
+	// The outputs add up to 0 once the sum wraps around
+	huge := transaction.Transaction{
+		Vin: []transaction.TXInput{{Txid: coinbase.ID, Vout: 0, PubKey: w.PublicKey}},
+		Vout: []transaction.TXOutput{
+			*transaction.NewTXOutput(math.MaxInt64, address),
+			*transaction.NewTXOutput(math.MaxInt64, address),
+			*transaction.NewTXOutput(2, address),
+		},
+	}
+	huge.ID = huge.Hash()
+	assert.NoError(t, huge.Sign(w.PrivateKey, map[string]transaction.Transaction{hex.EncodeToString(coinbase.ID): *coinbase}))
+
+	block := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy), &huge}, tip, 1, params.Active.PowLimitBits, blockTime(1))
+	_, _, err := bc.AddBlock(block)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectBadTransaction, err.(*BlockError).Reason)
+	}
+	assert.Equal(t, tip, bc.tip)
+
+	_, err = bc.MineBlock(address, []*transaction.Transaction{&huge})
+	assert.Error(t, err)
+	assert.Equal(t, tip, bc.tip)
+	assert.Equal(t, params.Active.InitialSubsidy, balance(t, bc, address))
+
+	tooMuch := transaction.Transaction{Vin: []transaction.TXInput{{Txid: coinbase.ID, Vout: 0, PubKey: w.PublicKey}}}
+	tooMuch.Vout = []transaction.TXOutput{*transaction.NewTXOutput(params.MaxMoney+1, address)}
+	tooMuch.ID = tooMuch.Hash()
+	assert.Equal(t, "output value out of range", checkTransaction(&tooMuch))
+}
+
+func TestFeeRejectsBadAmounts(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	tip := bc.tip
+	genesis, _ := bc.GetBlock(tip)
+	coinbase := genesis.Transactions[0]
+	address := string(w.GetAddress())
+	prevTXs := map[string]transaction.Transaction{hex.EncodeToString(coinbase.ID): *coinbase}
+
+	spend := func(values ...int) *transaction.Transaction {
+		tx := transaction.Transaction{Vin: []transaction.TXInput{{Txid: coinbase.ID, Vout: 0, PubKey: w.PublicKey}}}
+		for _, value := range values {
+			tx.Vout = append(tx.Vout, *transaction.NewTXOutput(value, address))
+		}
+		tx.ID = tx.Hash()
+		assert.NoError(t, tx.Sign(w.PrivateKey, prevTXs))
+
+		return &tx
+	}
+
+	fee, err := UTXOSet{bc}.Fee(spend(3, 4))
+	assert.NoError(t, err)
+	assert.Equal(t, params.Active.InitialSubsidy-7, fee)
+
+	// Wrapping outputs would make the whole input look like a fee
+	for _, tx := range []*transaction.Transaction{spend(math.MaxInt64, math.MaxInt64, 2), spend(params.Active.InitialSubsidy + 1)} {
+		_, err := UTXOSet{bc}.Fee(tx)
+		assert.True(t, errors.Is(err, ErrBadAmount), "%v", err)
+
+		_, err = bc.MineBlock(address, []*transaction.Transaction{tx})
+		assert.Error(t, err)
+		assert.Equal(t, tip, bc.tip)
+	}
+	assert.Equal(t, params.Active.InitialSubsidy, balance(t, bc, address))
+
+	// The fees can't make the coinbase claim more than MaxMoney either
+	block := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.MaxMoney+1)}, tip, 1, params.Active.PowLimitBits, blockTime(1))
+	assert.Error(t, checkCoinbaseValue(block, params.MaxMoney))
+	_, _, err = bc.AddBlock(block)
+	assert.IsType(t, &BlockError{}, err)
+	assert.Equal(t, tip, bc.tip)
+}
+
+func TestAddBlockRejectsGreedyCoinbase(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	tip := bc.tip
+
+	greedy := newCoinbaseTX(t, string(w.GetAddress()), "", params.Active.InitialSubsidy+1)
+	block := NewBlock([]*transaction.Transaction{greedy}, tip, 1, params.Active.PowLimitBits, blockTime(1))
+	_, _, err := bc.AddBlock(block)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectBadCoinbaseValue, err.(*BlockError).Reason)
+	}
+	assert.Equal(t, tip, bc.tip)
+}
+
+func TestCheckBlock(t *testing.T) {
+	address := string(newWallet(t).GetAddress())
+
+	block := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy)}, []byte("parent"), 1, params.Active.PowLimitBits, blockTime(1))
+	assert.NoError(t, checkBlock(block))
+
+	block.Nonce++
+	err := checkBlock(block)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectBadHash, err.(*BlockError).Reason)
+	}
+	block.Nonce--
+
+	block.Transactions = []*transaction.Transaction{newCoinbaseTX(t, address, "other", params.Active.InitialSubsidy)}
+	err = checkBlock(block)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectBadMerkleRoot, err.(*BlockError).Reason)
+	}
+
+	// Outputs must pay to a RIPEMD-160 hash
+	spend := transaction.Transaction{
+		Vin:  []transaction.TXInput{{Txid: []byte("previous"), Vout: 0}},
+		Vout: []transaction.TXOutput{*transaction.NewTXOutput(1, address)},
+	}
+	for _, pubKeyHash := range [][]byte{nil, make([]byte, pubKeyHashLen-1), make([]byte, pubKeyHashLen+1)} {
+		coinbase := newCoinbaseTX(t, address, "", params.Active.InitialSubsidy)
+		coinbase.Vout[0].PubKeyHash = pubKeyHash
+		coinbase.ID = coinbase.Hash()
+		block = NewBlock([]*transaction.Transaction{coinbase}, []byte("parent"), 1, params.Active.PowLimitBits, blockTime(1))
+		err = checkBlock(block)
+		if assert.IsType(t, &BlockError{}, err) {
+			assert.Equal(t, RejectBadTransaction, err.(*BlockError).Reason, "coinbase paying to %x", pubKeyHash)
+		}
+
+		tx := spend
+		tx.Vout = []transaction.TXOutput{{Value: 1, PubKeyHash: pubKeyHash}}
+		tx.ID = tx.Hash()
+		block = NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy), &tx}, []byte("parent"), 1, params.Active.PowLimitBits, blockTime(1))
+		err = checkBlock(block)
+		if assert.IsType(t, &BlockError{}, err) {
+			assert.Equal(t, RejectBadTransaction, err.(*BlockError).Reason, "transaction paying to %x", pubKeyHash)
+			assert.Equal(t, tx.ID, err.(*BlockError).TxID)
+		}
+	}
+
+	spend.ID = spend.Hash()
+	block = NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy), &spend}, []byte("parent"), 1, params.Active.PowLimitBits, blockTime(1))
+	assert.NoError(t, checkBlock(block))
+}
+
+func TestGetBlockHeader(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	genesis := bc.tip
+	block := mineBlock(t, bc, string(w.GetAddress()), nil)
+
+	header, err := bc.GetBlockHeader(block.Hash)
+	assert.NoError(t, err)
+	assert.Equal(t, block.Hash, header.Hash())
+	assert.Equal(t, genesis, header.PrevBlockHash)
+	assert.Equal(t, block.HashTransactions(), header.MerkleRoot)
+
+	assert.Equal(t, 1, bestHeight(t, bc))
+	hashes, err := bc.GetBlockHashes()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{block.Hash, genesis}, hashes)
+
+	_, err = bc.GetBlockHeader([]byte("unknown"))
+	assert.Equal(t, ErrBlockNotFound, err)
+
+	err = bc.db.Update(func(tx storage.Tx) error {
+		return tx.DeleteBucket([]byte(headersBucket))
+	})
+	assert.NoError(t, err)
+	reopened, err := OpenBlockchain(bc.db)
+	if assert.NoError(t, err, "DBs without headers get them rebuilt") {
+		header, err = reopened.GetBlockHeader(block.Hash)
+		assert.NoError(t, err)
+		assert.Equal(t, block.Hash, header.Hash())
+	}
+}
+
+func TestFindTransaction(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+
+	tx := newTransaction(t, bc, w, string(newWallet(t).GetAddress()), 1, 0)
+	block := mineBlock(t, bc, string(w.GetAddress()), []*transaction.Transaction{tx})
+
+	found, err := bc.FindTransaction(tx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tx.ID, found.ID)
+
+	count, err := bc.ReindexTransactions()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	found, err = bc.FindTransaction(block.Transactions[0].ID)
+	assert.NoError(t, err)
+	assert.True(t, found.IsCoinbase())
+
+	_, err = bc.FindTransaction([]byte("unknown"))
+	assert.Equal(t, ErrTransactionNotFound, err)
+}
+
+func TestGetBlockByHeight(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	genesis := bc.tip
+	a1 := mineBlock(t, bc, string(w.GetAddress()), nil)
+
+	block, err := bc.GetBlockByHeight(1)
+	assert.NoError(t, err)
+	assert.Equal(t, a1.Hash, block.Hash)
+
+	b1 := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, string(w.GetAddress()), "", params.Active.InitialSubsidy)}, genesis, 1, params.Active.PowLimitBits, blockTime(1))
+	b2 := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, string(w.GetAddress()), "", params.Active.InitialSubsidy)}, b1.Hash, 2, params.Active.PowLimitBits, blockTime(2))
+	bc.AddBlock(b1)
+	bc.AddBlock(b2)
+
+	hash, err := bc.GetBlockHash(1)
+	assert.NoError(t, err)
+	assert.Equal(t, b1.Hash, hash, "the index follows reorganizations")
+
+	hash, err = bc.GetBlockHash(0)
+	assert.NoError(t, err)
+	assert.Equal(t, genesis, hash)
+
+	_, err = bc.GetBlockByHeight(3)
+	assert.Error(t, err)
+}
+
+func TestFindAddressTransactions(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	to := newWallet(t)
+
+	_, err := bc.FindAddressTransactions(wallet.HashPubKey(w.PublicKey))
+	assert.Equal(t, ErrAddressIndexDisabled, err)
+	count, err := bc.ReindexAddresses()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	tx := newTransaction(t, bc, w, string(to.GetAddress()), 4, 1)
+	mineBlock(t, bc, string(to.GetAddress()), []*transaction.Transaction{tx})
+
+	history, err := bc.FindAddressTransactions(wallet.HashPubKey(w.PublicKey))
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, params.Active.InitialSubsidy, history[0].Received)
+		assert.Equal(t, 0, history[0].Sent)
+		assert.Equal(t, tx.ID, history[1].TxID)
+		assert.Equal(t, params.Active.InitialSubsidy-4-1, history[1].Received, "change")
+		assert.Equal(t, params.Active.InitialSubsidy, history[1].Sent)
+	}
+
+	history, err = bc.FindAddressTransactions(wallet.HashPubKey(to.PublicKey))
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2, "coinbase and payment") {
+		assert.Equal(t, params.Active.InitialSubsidy+1, history[0].Received)
+		assert.Equal(t, 4, history[1].Received)
+	}
+
+	count, err = bc.ReindexAddresses()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	// An output to a longer hash starting with the hash of to
+	longer := append(wallet.HashPubKey(to.PublicKey), 0xff)
+	at := AddressTransaction{TxID: tx.ID, BlockHash: bc.Tip(), Height: 1, Received: 1}
+	err = bc.db.Update(func(tx storage.Tx) error {
+		return tx.Bucket([]byte(addrIndexBucket)).Put(addrIndexKey(longer, 1, 0), at.Serialize())
+	})
+	assert.NoError(t, err)
+	history, err = bc.FindAddressTransactions(wallet.HashPubKey(to.PublicKey))
+	assert.NoError(t, err)
+	assert.Len(t, history, 2, "the history of an address is its own")
+}
+
+func TestRollbackTo(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	genesis := bc.tip
+	address := string(w.GetAddress())
+	to := string(newWallet(t).GetAddress())
+
+	tx := newTransaction(t, bc, w, to, 4, 0)
+	a1 := mineBlock(t, bc, address, []*transaction.Transaction{tx})
+	mineBlock(t, bc, address, nil)
+	assert.Equal(t, 3*params.Active.InitialSubsidy-4, balance(t, bc, address))
+
+	disconnected, err := bc.RollbackTo(0)
+	assert.NoError(t, err)
+	assert.Len(t, disconnected, 2)
+	assert.Equal(t, genesis, bc.tip)
+	assert.Equal(t, 0, bestHeight(t, bc))
+	assert.Equal(t, params.Active.InitialSubsidy, balance(t, bc, address), "spent outputs are restored")
+	assert.Equal(t, 0, balance(t, bc, to))
+
+	_, err = bc.FindTransaction(tx.ID)
+	assert.Equal(t, ErrTransactionNotFound, err)
+	_, err = bc.GetBlock(a1.Hash)
+	assert.Equal(t, ErrBlockNotFound, err, "rolled back blocks are removed")
+
+	_, _, err = bc.AddBlock(a1)
+	assert.NoError(t, err, "and can be added again")
+	assert.Equal(t, a1.Hash, bc.tip)
+	assert.Equal(t, 2*params.Active.InitialSubsidy-4, balance(t, bc, address))
+}
+
+func TestPrune(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+	genesis, _ := bc.GetBlock(bc.tip)
+
+	for i := 0; i < 3; i++ {
+		mineBlock(t, bc, address, nil)
+	}
+	pruned, err := bc.IsPruned()
+	assert.NoError(t, err)
+	assert.False(t, pruned)
+
+	count, err := bc.Prune(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, err = bc.Prune(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count, "pruning is incremental")
+	pruned, err = bc.IsPruned()
+	assert.NoError(t, err)
+	assert.True(t, pruned)
+
+	block, err := bc.GetBlockByHeight(1)
+	assert.NoError(t, err)
+	assert.True(t, block.IsPruned())
+	block, _ = bc.GetBlockByHeight(3)
+	assert.False(t, block.IsPruned())
+	assert.Equal(t, 3, bestHeight(t, bc))
+
+	_, err = bc.FindTransaction(genesis.Transactions[0].ID)
+	assert.Error(t, err)
+
+	tx := newTransaction(t, bc, w, string(newWallet(t).GetAddress()), 1, 0)
+	mineBlock(t, bc, address, []*transaction.Transaction{tx})
+	assert.Equal(t, 5*params.Active.InitialSubsidy-1, balance(t, bc, address), "outputs of pruned blocks can be spent")
+
+	fork := &genesis
+	for height := 1; height <= 5; height++ {
+		fork = NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy)}, fork.Hash, height, params.Active.PowLimitBits, blockTime(height))
+		_, _, err = bc.AddBlock(fork)
+		if err != nil {
+			break
+		}
+	}
+	assert.Equal(t, ErrPrunedReorganize, err)
+}
+
+func TestCheckpoints(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+	genesis, _ := bc.GetBlock(bc.tip)
+
+	a1 := mineBlock(t, bc, address, nil)
+	params.Active.Checkpoints = []params.Checkpoint{{Height: 1, Hash: hex.EncodeToString(a1.Hash)}, {Height: 2, Hash: "00"}}
+
+	b1 := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy)}, genesis.Hash, 1, params.Active.PowLimitBits, blockTime(1))
+	_, _, err := bc.AddBlock(b1)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectCheckpoint, err.(*BlockError).Reason)
+	}
+
+	a2 := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy)}, a1.Hash, 2, params.Active.PowLimitBits, blockTime(2))
+	_, _, err = bc.AddBlock(a2)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectCheckpoint, err.(*BlockError).Reason)
+	}
+	assert.Equal(t, a1.Hash, bc.tip)
+
+	// A checkpoint vouches only for the block it pins and its ancestors
+	params.Active.Checkpoints = []params.Checkpoint{{Height: 5, Hash: "00"}}
+	coinbase := genesis.Transactions[0]
+	thief := newWallet(t)
+	stolen := transaction.Transaction{
+		Vin:  []transaction.TXInput{{Txid: coinbase.ID, Vout: 0, PubKey: thief.PublicKey}},
+		Vout: []transaction.TXOutput{*transaction.NewTXOutput(params.Active.InitialSubsidy, string(thief.GetAddress()))},
+	}
+	stolen.ID = stolen.Hash()
+
+	a2 = NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy), &stolen}, a1.Hash, 2, params.Active.PowLimitBits, blockTime(2))
+	_, _, err = bc.AddBlock(a2)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectInvalidSignature, err.(*BlockError).Reason)
+	}
+	assert.Equal(t, a1.Hash, bc.tip)
+
+	params.Active.Checkpoints = []params.Checkpoint{{Height: 2, Hash: hex.EncodeToString(a2.Hash)}}
+	_, _, err = bc.AddBlock(a2)
+	assert.NoError(t, err)
+	assert.Equal(t, a2.Hash, bc.tip)
+}
+
+func TestBlockTimestamps(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+	genesis, _ := bc.GetBlock(bc.tip)
+
+	old := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy)}, genesis.Hash, 1, params.Active.PowLimitBits, genesis.Timestamp)
+	_, _, err := bc.AddBlock(old)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectTimeTooOld, err.(*BlockError).Reason)
+	}
+
+	future := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy)}, genesis.Hash, 1, params.Active.PowLimitBits, bc.adjustedTime()+maxFutureBlockTime+60)
+	_, _, err = bc.AddBlock(future)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectTimeTooNew, err.(*BlockError).Reason)
+	}
+
+	for i := 0; i < 3; i++ {
+		mineBlock(t, bc, address, nil)
+	}
+	tip, _ := bc.GetBlockHeader(bc.tip)
+	assert.True(t, bc.nextTimestamp(&tip) > bc.medianTimePast(&tip), "mined blocks follow the median time past")
+}
+
+func TestCoinbaseMaturity(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+	pubKeyHash := wallet.HashPubKey(w.PublicKey)
+	genesis, _ := bc.GetBlock(bc.tip)
+	params.Active.CoinbaseMaturity = 2
+
+	spendable, immature, err := UTXOSet{bc}.Balance(pubKeyHash)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, spendable)
+	assert.Equal(t, params.Active.InitialSubsidy, immature)
+
+	coinbase := genesis.Transactions[0]
+	spend := transaction.Transaction{
+		Vin:  []transaction.TXInput{{Txid: coinbase.ID, Vout: 0, PubKey: w.PublicKey}},
+		Vout: []transaction.TXOutput{*transaction.NewTXOutput(params.Active.InitialSubsidy, address)},
+	}
+	spend.ID = spend.Hash()
+	assert.NoError(t, bc.SignTransaction(&spend, w.PrivateKey))
+	assert.True(t, errors.Is(bc.VerifyTransaction(&spend), ErrImmatureSpend), "the mempool refuses immature spends")
+
+	block := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy), &spend}, genesis.Hash, 1, params.Active.PowLimitBits, blockTime(1))
+	_, _, err = bc.AddBlock(block)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectImmatureSpend, err.(*BlockError).Reason)
+	}
+
+	mineBlock(t, bc, address, nil)
+	spendable, immature, err = UTXOSet{bc}.Balance(pubKeyHash)
+	assert.NoError(t, err)
+	assert.Equal(t, params.Active.InitialSubsidy, spendable)
+	assert.Equal(t, params.Active.InitialSubsidy, immature)
+	assert.NoError(t, bc.VerifyTransaction(&spend))
+
+	tx := newTransaction(t, bc, w, string(newWallet(t).GetAddress()), params.Active.InitialSubsidy, 0)
+	assert.Equal(t, coinbase.ID, tx.Vin[0].Txid, "coin selection skips immature outputs")
+}
+
+func TestConcurrentUse(t *testing.T) {
+	source, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+	to := string(newWallet(t).GetAddress())
+
+	var blocks []*Block
+	for i := 0; i < 4; i++ {
+		tx := newTransaction(t, source, w, to, 1, 1)
+		blocks = append(blocks, mineBlock(t, source, address, []*transaction.Transaction{tx}))
+	}
+
+	bc, err := OpenBlockchain(storage.NewMemory())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer bc.Close()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for _, block := range blocks {
+			_, _, err := bc.AddBlock(block)
+			assert.NoError(t, err)
+		}
+	}()
+
+	// Blocks of a competing miner make the chain reorganize under the readers
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 2; i++ {
+			_, err := bc.MineBlock(to, nil)
+			assert.NoError(t, err)
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				_, err := bc.GetBestHeight()
+				assert.NoError(t, err)
+				_, err = bc.GetBlockHeader(bc.Tip())
+				assert.NoError(t, err)
+				_, err = UTXOSet{bc}.FindUTXO(wallet.HashPubKey(w.PublicKey))
+				assert.NoError(t, err)
+
+				bci := bc.Iterator()
+				for {
+					block, err := bci.Next()
+					if !assert.NoError(t, err) || len(block.PrevBlockHash) == 0 {
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	_, err = bc.VerifyChain(VerifyUTXO)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, bestHeight(t, bc), len(blocks))
+}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+// An export file starts with a header:
+//
+//	magic    bytes, exportMagic
+//	version  uint32, exportVersion
+//	genesis  bytes, the hash of the genesis block
+//
+// followed by the main chain blocks in height order, from the genesis block
+// on, each as bytes holding its canonical encoding. The primitives are
+// described in package utils.
+
+var exportMagic = []byte("blockchain-export")
+
+const exportVersion = 1
+
+// maxRecord bounds the size of a record in an export or snapshot file, so a
+// corrupt length doesn't make the reader allocate without limit
+const maxRecord = 64 << 20
+
+// ErrBadExportFile is returned when importing a file that isn't an export of
+// the blockchain of the network
+var ErrBadExportFile = errors.New("not an export of this network's blockchain")
+
+// ExportBlocks writes the main chain to w, in the format described above.
+// progress, when set, is called with the height of each block written. The
+// number of blocks written is returned. It needs every block, so ErrPruned is
+// returned on pruned nodes, and ErrMigrated when the chain was converted from
+// gob.
+func (bc *Blockchain) ExportBlocks(w io.Writer, progress func(height int)) (int, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	pruned, err := bc.IsPruned()
+	if err != nil {
+		return 0, err
+	}
+	if pruned {
+		return 0, ErrPruned
+	}
+
+	var migrated bool
+	err = bc.db.View(func(tx storage.Tx) error {
+		migrated = migratedHeight(tx) >= 0
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if migrated {
+		return 0, ErrMigrated
+	}
+
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return 0, err
+	}
+	genesisHash, err := bc.GetBlockHash(0)
+	if err != nil {
+		return 0, err
+	}
+
+	var header utils.Writer
+	header.VarBytes(exportMagic)
+	header.Uint32(exportVersion)
+	header.VarBytes(genesisHash)
+	if _, err := w.Write(header.Data()); err != nil {
+		return 0, err
+	}
+
+	for height := 0; height <= bestHeight; height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			return height, err
+		}
+
+		var record utils.Writer
+		record.VarBytes(block.Serialize())
+		if _, err := w.Write(record.Data()); err != nil {
+			return height, err
+		}
+
+		if progress != nil {
+			progress(height)
+		}
+	}
+
+	return bestHeight + 1, nil
+}
+
+// ImportBlocks reads an export file from r and adds its blocks to the
+// blockchain, validating each of them as if it came from the network.
+// Blocks the main chain already has are skipped, so an interrupted import
+// resumes where it stopped when run again. progress, when set, is called
+// with the height of each block added. The number of blocks added is
+// returned along with the first error met.
+func (bc *Blockchain) ImportBlocks(r io.Reader, progress func(height int)) (int, error) {
+	br := bufio.NewReader(r)
+
+	if err := readFileHeader(br, exportMagic, exportVersion); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrBadExportFile, err)
+	}
+
+	fileGenesis, err := readRecord(br)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrBadExportFile, err)
+	}
+	genesisHash, err := bc.GetBlockHash(0)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(fileGenesis, genesisHash) {
+		return 0, fmt.Errorf("%w: it starts with genesis block %x", ErrBadExportFile, fileGenesis)
+	}
+
+	counter := 0
+	for {
+		data, err := readRecord(br)
+		if err == io.EOF {
+			return counter, nil
+		}
+		if err != nil {
+			return counter, fmt.Errorf("export file: %w", err)
+		}
+
+		block, err := DeserializeBlock(data)
+		if err != nil {
+			return counter, err
+		}
+
+		if hash, err := bc.GetBlockHash(block.Height); err == nil && bytes.Equal(hash, block.Hash) {
+			continue
+		}
+
+		if _, _, err := bc.AddBlock(block); err != nil {
+			return counter, fmt.Errorf("importing the block at height %d: %w", block.Height, err)
+		}
+		counter++
+
+		if progress != nil {
+			progress(block.Height)
+		}
+	}
+}
+
+// readFileHeader reads the magic bytes and the version an export or
+// snapshot file starts with, and checks them
+func readFileHeader(r *bufio.Reader, magic []byte, version uint32) error {
+	fileMagic, err := readRecord(r)
+	if err != nil {
+		return noEOF(err)
+	}
+	if !bytes.Equal(fileMagic, magic) {
+		return fmt.Errorf("the file starts with %q", fileMagic)
+	}
+
+	var v [4]byte
+	if _, err := io.ReadFull(r, v[:]); err != nil {
+		return noEOF(err)
+	}
+	if fileVersion := binary.BigEndian.Uint32(v[:]); fileVersion != version {
+		return fmt.Errorf("unknown version %d", fileVersion)
+	}
+
+	return nil
+}
+
+// readRecord reads a length-prefixed record of an export or snapshot file.
+// io.EOF is only returned when the file ends before the record starts.
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	if size > maxRecord {
+		return nil, fmt.Errorf("%w: record of %d bytes", utils.ErrMalformed, size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, noEOF(err)
+	}
+
+	return data, nil
+}
+
+// noEOF turns io.EOF met within a record into io.ErrUnexpectedEOF
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+func TestExportImportBlocks(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+	to := string(newWallet(t).GetAddress())
+
+	mineBlock(t, bc, address, []*transaction.Transaction{newTransaction(t, bc, w, to, 3, 1)})
+	mineBlock(t, bc, address, nil)
+	mineBlock(t, bc, address, []*transaction.Transaction{newTransaction(t, bc, w, to, 2, 0)})
+
+	var file bytes.Buffer
+	var exported []int
+	count, err := bc.ExportBlocks(&file, func(height int) { exported = append(exported, height) })
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, []int{0, 1, 2, 3}, exported)
+
+	// The same network parameters build the same genesis block
+	fresh, err := OpenBlockchain(storage.NewMemory())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer fresh.Close()
+
+	// An import cut short keeps the blocks read so far
+	cut := file.Bytes()[:file.Len()-10]
+	count, err = fresh.ImportBlocks(bytes.NewReader(cut), nil)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 2, bestHeight(t, fresh))
+
+	var imported []int
+	count, err = fresh.ImportBlocks(bytes.NewReader(file.Bytes()), func(height int) { imported = append(imported, height) })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count, "resuming skips the blocks already connected")
+	assert.Equal(t, []int{3}, imported)
+	assert.Equal(t, bc.Tip(), fresh.Tip())
+	assert.Equal(t, 5, balance(t, fresh, to))
+
+	_, err = fresh.VerifyChain(VerifyUTXO)
+	assert.NoError(t, err)
+
+	_, err = fresh.ImportBlocks(bytes.NewReader([]byte("not an export")), nil)
+	assert.True(t, errors.Is(err, ErrBadExportFile))
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+)
+
+// checkpointHash returns the hash pinned at the height on the network, or nil
+// when there is no checkpoint at that height
+func checkpointHash(height int) []byte {
+	for _, cp := range params.Active.Checkpoints {
+		if cp.Height == height {
+			// The hashes are checked when the parameters load
+			hash, err := hex.DecodeString(cp.Hash)
+			if err != nil {
+				return nil
+			}
+
+			return hash
+		}
+	}
+
+	return nil
+}
+
+// isCheckpointed tells whether the block is the block pinned by the first
+// checkpoint at or above its height, or one of its ancestors. The ancestry is
+// followed through the stored headers, so it is false while the header of the
+// pinned block isn't stored.
+func isCheckpointed(tx storage.Tx, block *Block) bool {
+	var hash []byte
+	for _, cp := range params.Active.Checkpoints {
+		if cp.Height >= block.Height {
+			hash = checkpointHash(cp.Height)
+			break
+		}
+	}
+
+	h := tx.Bucket([]byte(headersBucket))
+	for len(hash) > 0 && h != nil {
+		header, err := DeserializeBlockHeader(h.Get(hash))
+		if err != nil {
+			return false
+		}
+		if header.Height <= block.Height {
+			return header.Height == block.Height && bytes.Equal(hash, block.Hash)
+		}
+
+		hash = header.PrevBlockHash
+	}
+
+	return false
+}
+
+// checkCheckpoints rejects a block that conflicts with a checkpoint: one at a
+// checkpoint height with another hash, or one forking off the main chain
+// below a checkpoint the main chain has already passed
+func (bc *Blockchain) checkCheckpoints(block *Block) error {
+	if hash := checkpointHash(block.Height); hash != nil && bytes.Compare(hash, block.Hash) != 0 {
+		return rejectBlock(block, RejectCheckpoint, "expected %x at height %d", hash, block.Height)
+	}
+
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	cps := params.Active.Checkpoints
+	for i := len(cps) - 1; i >= 0; i-- {
+		if cps[i].Height > bestHeight {
+			continue
+		}
+
+		// The main chain fills every height up to the checkpoint, so a new
+		// block at such a height belongs to a competing branch
+		if block.Height <= cps[i].Height {
+			return rejectBlock(block, RejectCheckpoint, "forks below the checkpoint at height %d", cps[i].Height)
+		}
+		break
+	}
+
+	return nil
+}
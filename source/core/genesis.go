@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+// ErrGenesisMismatch is returned when the genesis block built from the
+// network parameters doesn't have the pinned hash, e.g. because the premine
+// file was edited
+var ErrGenesisMismatch = errors.New("the genesis block doesn't match the network's genesis hash")
+
+// Allocation is an amount of coins the genesis block pays to an address
+type Allocation struct {
+	Address string
+	Amount  int
+}
+
+// LoadAllocations reads a premine allocation file: a JSON list of
+// allocations, paid by the genesis block in the listed order
+func LoadAllocations(path string) ([]Allocation, error) {
+	var allocations []Allocation
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &allocations); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	for _, a := range allocations {
+		if !wallet.ValidateAddress(a.Address) {
+			return nil, fmt.Errorf("%s: address %s is not valid on %s", path, a.Address, params.Active.Name)
+		}
+		if a.Amount <= 0 {
+			return nil, fmt.Errorf("%s: the allocation of %s is not positive", path, a.Address)
+		}
+	}
+
+	return allocations, nil
+}
+
+// newGenesisCoinbase creates the coinbase of the genesis block. It pays the
+// allocations or, without any, the block subsidy to an output nobody can
+// spend. That output has no public key hash, which checkTransaction rejects
+// in any other block: the genesis block is pinned by the network parameters
+// instead.
+func newGenesisCoinbase(data string, allocations []Allocation) *transaction.Transaction {
+	var outputs []transaction.TXOutput
+
+	for _, a := range allocations {
+		outputs = append(outputs, *transaction.NewTXOutput(a.Amount, a.Address))
+	}
+	if len(outputs) == 0 {
+		outputs = append(outputs, transaction.TXOutput{Value: blockSubsidy(0)})
+	}
+
+	txin := transaction.TXInput{Txid: []byte{}, Vout: -1, PubKey: []byte(data)}
+	tx := transaction.Transaction{Vin: []transaction.TXInput{txin}, Vout: outputs}
+	tx.ID = tx.Hash()
+
+	return &tx
+}
+
+// GenesisBlock builds the genesis block of the network. Every field comes from
+// the network parameters and the nonce search starts at zero, so every node
+// builds the same block.
+func GenesisBlock() (*Block, error) {
+	var allocations []Allocation
+
+	if params.Active.PremineFile != "" {
+		var err error
+		if allocations, err = LoadAllocations(params.Active.PremineFile); err != nil {
+			return nil, err
+		}
+	}
+
+	genesis := NewGenesisBlock(newGenesisCoinbase(params.Active.GenesisCoinbaseData, allocations))
+
+	if pinned := params.Active.PinnedGenesisHash(); pinned != nil && bytes.Compare(pinned, genesis.Hash) != 0 {
+		return nil, ErrGenesisMismatch
+	}
+
+	return genesis, nil
+}
+
+// checkGenesis checks that the chain starts with the genesis block of the
+// network. Chains converted from gob are taken as they are, the network
+// builds its genesis block from the canonical encoding since.
+func (bc *Blockchain) checkGenesis() error {
+	var migrated bool
+	err := bc.db.View(func(tx storage.Tx) error {
+		migrated = migratedHeight(tx) >= 0
+		return nil
+	})
+	if err != nil || migrated {
+		return err
+	}
+
+	expected := params.Active.PinnedGenesisHash()
+	if expected == nil {
+		genesis, err := GenesisBlock()
+		if err != nil {
+			return err
+		}
+		expected = genesis.Hash
+	}
+
+	hash, err := bc.GetBlockHash(0)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Compare(hash, expected) != 0 {
+		return fmt.Errorf("genesis block %x, expected %x", hash, expected)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+)
+
+func TestGenesisBlock(t *testing.T) {
+	saved := params.Active
+	defer func() { params.Active = saved }()
+
+	for _, p := range []*params.ChainParams{&params.MainNet, &params.TestNet, &params.RegTest} {
+		params.Active = p
+		genesis, err := GenesisBlock()
+		if assert.NoError(t, err, p.Name) {
+			assert.Equal(t, p.PinnedGenesisHash(), genesis.Hash, p.Name)
+			assert.Equal(t, p.GenesisTimestamp, genesis.Timestamp, p.Name)
+			assert.Equal(t, checkpointHash(0), genesis.Hash, p.Name)
+		}
+	}
+
+	custom := params.RegTest
+	custom.PremineFile = filepath.Join(t.TempDir(), "premine.json")
+	params.Active = &custom
+	assert.NoError(t, ioutil.WriteFile(custom.PremineFile, []byte(`[]`), 0644))
+	_, err := GenesisBlock()
+	assert.NoError(t, err, "an empty premine builds the preset genesis")
+
+	address := string(newWallet(t).GetAddress())
+	assert.NoError(t, ioutil.WriteFile(custom.PremineFile, []byte(`[{"Address": "`+address+`", "Amount": 1000}]`), 0644))
+	_, err = GenesisBlock()
+	assert.Equal(t, ErrGenesisMismatch, err)
+
+	custom.GenesisHash = ""
+	genesis, err := GenesisBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, genesis.Transactions[0].Vout[0].Value)
+
+	again, _ := GenesisBlock()
+	assert.Equal(t, genesis.Hash, again.Hash, "the genesis block is reproducible")
+
+	assert.NoError(t, ioutil.WriteFile(custom.PremineFile, []byte(`[{"Address": "`+address+`", "Amount": 0}]`), 0644))
+	_, err = GenesisBlock()
+	assert.Error(t, err)
+}
+
+func TestCheckGenesis(t *testing.T) {
+	bc, _ := newTestBlockchain(t)
+	assert.NoError(t, bc.checkGenesis())
+
+	params.Active.GenesisHash = params.RegTest.GenesisHash
+	assert.Error(t, bc.checkGenesis(), "the premine makes it another network")
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+const heightIndexBucket = "heightindex"
+
+// indexHeight records the block as the main chain block at its height
+func indexHeight(b storage.Bucket, block *Block) error {
+	return b.Put(utils.IntToHex(int64(block.Height)), block.Hash)
+}
+
+// unindexHeight removes the block disconnected from the main chain from the
+// height index
+func unindexHeight(b storage.Bucket, block *Block) error {
+	return b.Delete(utils.IntToHex(int64(block.Height)))
+}
+
+// reindexHeights rebuilds the height index from the headers of the main chain
+func (bc *Blockchain) reindexHeights() error {
+	bucketName := []byte(heightIndexBucket)
+
+	return bc.db.Update(func(tx storage.Tx) error {
+		err := tx.DeleteBucket(bucketName)
+		if err != nil && err != storage.ErrBucketNotFound {
+			return err
+		}
+
+		h, err := tx.CreateBucket(bucketName)
+		if err != nil {
+			return err
+		}
+
+		hb := tx.Bucket([]byte(headersBucket))
+		for hash := bc.tip; len(hash) > 0; {
+			header, err := DeserializeBlockHeader(hb.Get(hash))
+			if err != nil {
+				return err
+			}
+			err = h.Put(utils.IntToHex(int64(header.Height)), hash)
+			if err != nil {
+				return err
+			}
+
+			hash = header.PrevBlockHash
+		}
+
+		return nil
+	})
+}
+
+// GetBlockHash returns the hash of the main chain block at the given height,
+// or ErrBlockNotFound when the main chain is shorter
+func (bc *Blockchain) GetBlockHash(height int) ([]byte, error) {
+	var hash []byte
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(heightIndexBucket))
+		if data := b.Get(utils.IntToHex(int64(height))); data != nil {
+			hash = append([]byte{}, data...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if hash == nil {
+		return nil, fmt.Errorf("%w: no main chain block at height %d", ErrBlockNotFound, height)
+	}
+
+	return hash, nil
+}
+
+// GetBlockByHeight returns the main chain block at the given height
+func (bc *Blockchain) GetBlockByHeight(height int) (Block, error) {
+	hash, err := bc.GetBlockHash(height)
+	if err != nil {
+		return Block{}, err
+	}
+
+	return bc.GetBlock(hash)
+}
@@ -0,0 +1,322 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+// metaBucket holds facts about the DB rather than about the chain
+const metaBucket = "meta"
+
+// formatKey maps to the version of the encoding the records of the DB are
+// written in. DBs without it were written with encoding/gob.
+var formatKey = []byte("format")
+
+// migratedKey maps to the height of the tip of a DB converted from gob, as
+// a big-endian int64
+var migratedKey = []byte("migrated")
+
+// migratedBucket holds the hashes of the blocks converted from gob
+const migratedBucket = "migrated"
+
+// ErrMigrated is returned when blocks converted from gob would be handed to
+// other nodes. Their transaction IDs and Merkle roots were computed over gob
+// data, so nodes that didn't convert them reject them.
+var ErrMigrated = errors.New("blocks converted from gob can't be validated by other nodes")
+
+// dbFormat is the version of the canonical encoding
+const dbFormat = 1
+
+// writeFormat records that the DB is written in the canonical encoding
+func writeFormat(tx storage.Tx) error {
+	m, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return err
+	}
+
+	return m.Put(formatKey, []byte{dbFormat})
+}
+
+// isGobFormat checks whether the records of an existing DB were written with
+// encoding/gob
+func isGobFormat(tx storage.Tx) bool {
+	m := tx.Bucket([]byte(metaBucket))
+
+	return m == nil || m.Get(formatKey) == nil
+}
+
+// migratedHeight returns the height of the tip of the main chain converted
+// from gob, or -1 if the DB was never converted
+func migratedHeight(tx storage.Tx) int {
+	if m := tx.Bucket([]byte(metaBucket)); m != nil {
+		if data := m.Get(migratedKey); data != nil {
+			return int(utils.HexToInt(data))
+		}
+	}
+
+	return -1
+}
+
+// isMigrated tells whether the block with the hash was converted from gob.
+// Its Merkle root, transaction IDs and signatures were computed over gob
+// data and can't be checked.
+func isMigrated(tx storage.Tx, hash []byte) bool {
+	m := tx.Bucket([]byte(migratedBucket))
+
+	return m != nil && m.Get(hash) != nil
+}
+
+// IsMigrated tells whether the block with the hash was converted from gob.
+// Such blocks are not served to other nodes, see ErrMigrated.
+func (bc *Blockchain) IsMigrated(hash []byte) (bool, error) {
+	var migrated bool
+	err := bc.db.View(func(tx storage.Tx) error {
+		migrated = isMigrated(tx, hash)
+		return nil
+	})
+
+	return migrated, err
+}
+
+// checksSignatures tells whether the signatures of the block are checked when
+// it is connected. A checkpoint vouches for the block it pins and its
+// ancestors, and blocks converted from gob were signed over data that is gone.
+// Any other block, on any branch, is checked.
+func checksSignatures(tx storage.Tx, block *Block) bool {
+	return !isCheckpointed(tx, block) && !isMigrated(tx, block.Hash)
+}
+
+// legacyBlockVersion is the version of the blocks converted from gob. The
+// old nodes hashed their blocks over the Merkle root of the gob encoding of
+// the transactions, which depended on the types the process had encoded
+// before, and never stored it. It can't be computed again, so the headers of
+// the converted blocks carry the hash of the block in its place.
+const legacyBlockVersion = 0
+
+const (
+	// legacyTargetBits is the fixed difficulty of the old nodes: a block hash
+	// had to be below 2^(256-legacyTargetBits)
+	legacyTargetBits = 16
+	// legacyBits is the target of the old nodes in compact form
+	legacyBits = 0x1f010000
+)
+
+// legacyBlock is a block as the old nodes encoded it with encoding/gob,
+// before blocks had a header. Their transactions had the fields of
+// transaction.Transaction and decode into it.
+type legacyBlock struct {
+	Timestamp     int64
+	Transactions  []*transaction.Transaction
+	PrevBlockHash []byte
+	Hash          []byte
+	Nonce         int
+	Height        int
+}
+
+// convert returns the block with a header of legacyBlockVersion
+func (lb *legacyBlock) convert() (*Block, error) {
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version:       legacyBlockVersion,
+			PrevBlockHash: lb.PrevBlockHash,
+			MerkleRoot:    lb.Hash,
+			Timestamp:     lb.Timestamp,
+			Bits:          legacyBits,
+			Nonce:         lb.Nonce,
+			Height:        lb.Height,
+		},
+		Hash:         lb.Hash,
+		Transactions: lb.Transactions,
+	}
+	if len(block.Transactions) == 0 || !NewProofOfWork(&block.BlockHeader).Validate() {
+		return nil, fmt.Errorf("block %x wasn't mined by the old nodes", lb.Hash)
+	}
+
+	return block, nil
+}
+
+// connectLegacyBlock is connectBlock for a block converted from gob. The old
+// nodes compacted the unspent outputs of a transaction as they were spent,
+// and an input named an output by its position among those left when the
+// input was applied; the undo data records the original index. Like the old
+// nodes, it enforces no maturity, value or signature rule.
+func connectLegacyBlock(b storage.Bucket, block *Block) (BlockUndo, error) {
+	var undo BlockUndo
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() == false {
+			for _, vin := range tx.Vin {
+				outsBytes := b.Get(vin.Txid)
+				if outsBytes == nil {
+					return undo, rejectTransaction(block, tx, RejectMissingInput, "output %x:%d", vin.Txid, vin.Vout)
+				}
+				outs, err := transaction.DeserializeOutputs(outsBytes)
+				if err != nil {
+					return undo, err
+				}
+
+				var indexes []int
+				for i := range outs.Outputs {
+					indexes = append(indexes, i)
+				}
+				sort.Ints(indexes)
+				if vin.Vout < 0 || vin.Vout >= len(indexes) {
+					return undo, rejectTransaction(block, tx, RejectMissingInput, "output %x:%d", vin.Txid, vin.Vout)
+				}
+
+				spent, err := spendOutput(b, vin.Txid, indexes[vin.Vout])
+				if err != nil {
+					return undo, err
+				}
+				undo.Spent = append(undo.Spent, spent)
+			}
+		}
+
+		if err := addOutputs(b, block, tx); err != nil {
+			return undo, err
+		}
+	}
+
+	return undo, nil
+}
+
+// migrateFromGob converts a DB written by the old nodes with encoding/gob.
+// Their blocks are rewritten in the canonical encoding as blocks of
+// legacyBlockVersion and keep their hashes. Their transactions keep the IDs
+// computed over gob data and their signatures, so the converted blocks stay
+// usable by this node only: they are neither exported nor served to peers,
+// which would reject them. The old nodes kept no headers, chain work, undo
+// data or indexes, and their UTXO set lost the indexes of the outputs, so
+// all of them are built from the blocks, replaying the main chain.
+func migrateFromGob(tx storage.Tx) error {
+	b := tx.Bucket([]byte(blocksBucket))
+	tip := append([]byte{}, b.Get([]byte("l"))...)
+
+	// Collect the records first, cursors don't survive writes
+	var records [][]byte
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if !bytes.Equal(k, []byte("l")) {
+			records = append(records, append([]byte{}, v...))
+		}
+	}
+
+	migrated, err := tx.CreateBucketIfNotExists([]byte(migratedBucket))
+	if err != nil {
+		return err
+	}
+
+	blocks := make(map[string]*Block)
+	for _, data := range records {
+		var lb legacyBlock
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&lb); err != nil {
+			return fmt.Errorf("decoding a gob block: %w", err)
+		}
+		block, err := lb.convert()
+		if err != nil {
+			return err
+		}
+
+		if err := b.Put(block.Hash, block.Serialize()); err != nil {
+			return err
+		}
+		if err := migrated.Put(block.Hash, []byte{1}); err != nil {
+			return err
+		}
+		blocks[hex.EncodeToString(block.Hash)] = block
+	}
+
+	if err := indexHeaders(tx); err != nil {
+		return err
+	}
+	if err := storeLegacyChainWork(tx, blocks); err != nil {
+		return err
+	}
+
+	var mainChain []*Block
+	for hash := tip; len(hash) > 0; {
+		block, ok := blocks[hex.EncodeToString(hash)]
+		if !ok {
+			return fmt.Errorf("block %x of the main chain is missing", hash)
+		}
+		mainChain = append(mainChain, block)
+		hash = block.PrevBlockHash
+	}
+	if len(mainChain) == 0 {
+		return errors.New("the DB has no main chain")
+	}
+
+	for _, name := range []string{utxoBucket, undoBucket, txIndexBucket, heightIndexBucket} {
+		if err := tx.DeleteBucket([]byte(name)); err != nil && err != storage.ErrBucketNotFound {
+			return err
+		}
+		if _, err := tx.CreateBucket([]byte(name)); err != nil {
+			return err
+		}
+	}
+
+	genesis := mainChain[len(mainChain)-1]
+	if err := connectGenesis(tx.Bucket([]byte(utxoBucket)), genesis); err != nil {
+		return err
+	}
+	if err := indexTransactions(tx.Bucket([]byte(txIndexBucket)), genesis); err != nil {
+		return err
+	}
+	if err := indexHeight(tx.Bucket([]byte(heightIndexBucket)), genesis); err != nil {
+		return err
+	}
+	for i := len(mainChain) - 2; i >= 0; i-- {
+		if err := connectToMainChain(tx, mainChain[i]); err != nil {
+			return fmt.Errorf("replaying block %x: %w", mainChain[i].Hash, err)
+		}
+	}
+
+	if err := writeFormat(tx); err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte(metaBucket)).Put(migratedKey, utils.IntToHex(int64(mainChain[0].Height)))
+}
+
+// storeLegacyChainWork stores the cumulative work of the converted blocks, on
+// every branch
+func storeLegacyChainWork(tx storage.Tx, blocks map[string]*Block) error {
+	w, err := tx.CreateBucketIfNotExists([]byte(chainWorkBucket))
+	if err != nil {
+		return err
+	}
+
+	sorted := make([]*Block, 0, len(blocks))
+	for _, block := range blocks {
+		sorted = append(sorted, block)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Height < sorted[j].Height })
+
+	for _, block := range sorted {
+		work := NewProofOfWork(&block.BlockHeader).Work()
+		if len(block.PrevBlockHash) > 0 {
+			// The old nodes kept blocks whose parent they didn't have,
+			// those stay out of every branch
+			parentWork := w.Get(block.PrevBlockHash)
+			if parentWork == nil {
+				continue
+			}
+			work.Add(work, new(big.Int).SetBytes(parentWork))
+		}
+
+		if err := w.Put(block.Hash, work.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
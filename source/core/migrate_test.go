@@ -0,0 +1,218 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+// The wallets of testdata/baseline.db. The DB was written by the nodes
+// released before the canonical encoding: A created the chain, sent 3 to B,
+// who sent them on to C, and A sent the 17 left to C, spending the change of
+// its first transaction at its compacted index. Each sender mined its block.
+const (
+	baselineA = "13hK9mCkGHC1UDsEv7St9dAoGaRmtLxD6P"
+	baselineB = "16XJhc29J9EuraRPxdo135mVZsMwXoZXWn"
+	baselineC = "18KT8NvtePUSeaJAHDdtByewPVCPcq8nTk"
+)
+
+// openBaseline copies the records of testdata/baseline.db to memory storage
+// and opens it under the mainnet parameters, with coinbase outputs
+// spendable at once
+func openBaseline(t *testing.T) *Blockchain {
+	saved := params.Active
+	testParams := params.MainNet
+	testParams.CoinbaseMaturity = 0
+	params.Active = &testParams
+
+	path := filepath.Join(t.TempDir(), "baseline.db")
+	data, err := ioutil.ReadFile("testdata/baseline.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := storage.OpenBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	db := storage.NewMemory()
+	err = file.View(func(ftx storage.Tx) error {
+		return db.Update(func(tx storage.Tx) error {
+			for _, name := range []string{blocksBucket, utxoBucket} {
+				b, err := tx.CreateBucket([]byte(name))
+				if err != nil {
+					return err
+				}
+				c := ftx.Bucket([]byte(name)).Cursor()
+				for k, v := c.First(); k != nil; k, v = c.Next() {
+					if err := b.Put(append([]byte{}, k...), append([]byte{}, v...)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc, err := OpenBlockchain(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		bc.db.Close()
+		params.Active = saved
+	})
+
+	return bc
+}
+
+func TestMigrateFromGob(t *testing.T) {
+	bc := openBaseline(t)
+	assert.Equal(t, 3, bestHeight(t, bc))
+	assert.Equal(t, 0, balance(t, bc, baselineA))
+	assert.Equal(t, 10, balance(t, bc, baselineB))
+	assert.Equal(t, 30, balance(t, bc, baselineC), "inputs name the outputs by their compacted index")
+
+	for height := 0; height <= 3; height++ {
+		hash, err := bc.GetBlockHash(height)
+		if !assert.NoError(t, err, "the height index is built") {
+			return
+		}
+		header, err := bc.GetBlockHeader(hash)
+		if !assert.NoError(t, err, "the headers are built") {
+			return
+		}
+		assert.Equal(t, hash, header.Hash(), "blocks keep their hashes")
+		assert.Equal(t, legacyBlockVersion, header.Version)
+
+		isMigrated, err := bc.IsMigrated(hash)
+		assert.NoError(t, err)
+		assert.True(t, isMigrated)
+	}
+
+	_, err := bc.VerifyChain(VerifyUTXO)
+	assert.NoError(t, err, "the converted chain holds to the rules of the old nodes")
+
+	_, err = bc.ExportBlocks(ioutil.Discard, nil)
+	assert.Equal(t, ErrMigrated, err, "other nodes can't validate the converted blocks")
+
+	miner := string(newWallet(t).GetAddress())
+	newBlock := mineBlock(t, bc, miner, nil)
+	assert.Equal(t, 4, newBlock.Height, "the converted chain keeps growing")
+	isMigrated, err := bc.IsMigrated(newBlock.Hash)
+	assert.NoError(t, err)
+	assert.False(t, isMigrated)
+	_, err = bc.VerifyChain(VerifyUTXO)
+	assert.NoError(t, err)
+
+	again, err := OpenBlockchain(bc.db)
+	assert.NoError(t, err, "the conversion happens once")
+	assert.Equal(t, newBlock.Hash, again.Tip())
+
+	_, err = bc.RollbackTo(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 17, balance(t, bc, baselineA), "the undo data restores the original indexes")
+	assert.Equal(t, 3, balance(t, bc, baselineB))
+	assert.Equal(t, 0, balance(t, bc, baselineC))
+	assert.Equal(t, 0, balance(t, bc, miner))
+}
+
+func TestLegacyBits(t *testing.T) {
+	target := new(big.Int).Lsh(big.NewInt(1), 256-legacyTargetBits)
+	assert.Equal(t, 0, target.Cmp(params.CompactToBig(legacyBits)))
+}
+
+func TestLegacyVersionIsRejected(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+
+	block := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy)}, bc.Tip(), 1, params.Active.PowLimitBits, blockTime(1))
+	block.Version = legacyBlockVersion
+	block.MerkleRoot = block.Hash
+
+	_, _, err := bc.AddBlock(block)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectBadVersion, err.(*BlockError).Reason, "only converted blocks carry their hash")
+	}
+}
+
+func TestGobTransactionIDsAreRejected(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+	tx := newTransaction(t, bc, w, address, 3, 0)
+
+	// Before the canonical encoding, IDs were the hash of the gob encoding
+	var buff bytes.Buffer
+	unsigned := *tx
+	unsigned.ID = nil
+	assert.NoError(t, gob.NewEncoder(&buff).Encode(unsigned))
+	hash := sha256.Sum256(buff.Bytes())
+	tx.ID = hash[:]
+
+	block := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, address, "", params.Active.InitialSubsidy), tx}, bc.Tip(), 1, params.Active.PowLimitBits, blockTime(1))
+	err := checkBlock(block)
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectBadTransaction, err.(*BlockError).Reason)
+	}
+}
+
+func TestMigratedForkChecksSignatures(t *testing.T) {
+	bc := openBaseline(t)
+	tip := bc.Tip()
+	genesisHash, err := bc.GetBlockHash(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis, err := bc.GetBlock(genesisHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase := genesis.Transactions[0]
+
+	// A fork below the converted tip spends the genesis coinbase with a
+	// forged signature
+	thief := newWallet(t)
+	stolen := transaction.Transaction{
+		Vin:  []transaction.TXInput{{Txid: coinbase.ID, Vout: 0, PubKey: thief.PublicKey}},
+		Vout: []transaction.TXOutput{*transaction.NewTXOutput(coinbase.Vout[0].Value, string(thief.GetAddress()))},
+	}
+	stolen.ID = stolen.Hash()
+	assert.NoError(t, stolen.Sign(thief.PrivateKey, map[string]transaction.Transaction{hex.EncodeToString(coinbase.ID): *coinbase}))
+
+	thiefAddress := string(thief.GetAddress())
+	fork := []*Block{NewBlock([]*transaction.Transaction{newCoinbaseTX(t, thiefAddress, "", params.Active.InitialSubsidy), &stolen}, genesis.Hash, 1, params.Active.PowLimitBits, blockTime(1))}
+	for height := 2; height <= 4; height++ {
+		fork = append(fork, NewBlock([]*transaction.Transaction{newCoinbaseTX(t, thiefAddress, "", params.Active.InitialSubsidy)}, fork[len(fork)-1].Hash, height, params.Active.PowLimitBits, blockTime(height)))
+	}
+
+	// On equal work the fork may take over a block early
+	for _, block := range fork {
+		if _, _, err = bc.AddBlock(block); err != nil {
+			break
+		}
+	}
+	if assert.IsType(t, &BlockError{}, err) {
+		assert.Equal(t, RejectInvalidSignature, err.(*BlockError).Reason)
+	}
+	assert.Equal(t, tip, bc.Tip())
+	assert.Equal(t, 0, balance(t, bc, thiefAddress))
+}
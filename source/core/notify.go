@@ -0,0 +1,170 @@
+package core
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+// EventType tells what an Event reports
+type EventType int
+
+const (
+	// BlockConnected reports a block added to the main chain
+	BlockConnected EventType = iota + 1
+	// BlockDisconnected reports a block removed from the main chain by a
+	// reorganization or a rollback
+	BlockDisconnected
+	// NewTip reports the last block of the main chain once a change is
+	// complete
+	NewTip
+	// TxAccepted reports a transaction added to the mempool
+	TxAccepted
+	// TxRemoved reports a transaction leaving the mempool
+	TxRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case BlockConnected:
+		return "block connected"
+	case BlockDisconnected:
+		return "block disconnected"
+	case NewTip:
+		return "new tip"
+	case TxAccepted:
+		return "transaction accepted"
+	case TxRemoved:
+		return "transaction removed"
+	}
+
+	return "unknown event"
+}
+
+// Event is a change of the main chain or of the mempool. Hash and Height
+// identify the block of block events and the new tip of NewTip events. For
+// TxRemoved they identify the block that included the transaction, and are
+// empty when it was dropped for another reason.
+type Event struct {
+	Type   EventType
+	Hash   []byte
+	Height int
+	// Block is set for BlockConnected and BlockDisconnected
+	Block *Block
+	// Tx is set for TxAccepted and TxRemoved
+	Tx *transaction.Transaction
+}
+
+// ErrSlowSubscriber is returned by Subscription.Err when events were dropped
+// because the subscriber didn't receive them fast enough
+var ErrSlowSubscriber = errors.New("the subscriber fell behind, events were dropped")
+
+// Notifier delivers events to subscribers, each in the order they were
+// published. Publishers never wait for subscribers: the chain publishes
+// while changes are locked out. A subscriber whose buffer is full is
+// dropped instead, and should resync from the chain state, e.g. Tip, before
+// subscribing again. The zero value is ready to use.
+type Notifier struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives the events published after it was made
+type Subscription struct {
+	n      *Notifier
+	events chan Event
+	err    error
+}
+
+// Subscribe registers a subscriber that can fall behind by up to buffer
+// events. A reorganization publishes an event per block it disconnects or
+// connects, so the buffer should absorb the deepest one expected.
+func (n *Notifier) Subscribe(buffer int) *Subscription {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	s := &Subscription{n: n, events: make(chan Event, buffer)}
+	if n.subs == nil {
+		n.subs = make(map[*Subscription]struct{})
+	}
+	n.subs[s] = struct{}{}
+
+	return s
+}
+
+// Publish delivers the events to every subscriber
+func (n *Notifier) Publish(events ...Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for s := range n.subs {
+		for _, event := range events {
+			select {
+			case s.events <- event:
+				continue
+			default:
+			}
+
+			s.err = ErrSlowSubscriber
+			s.close()
+			break
+		}
+	}
+}
+
+// Events returns the channel the events are delivered on. It is closed when
+// the subscription ends, see Err.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns ErrSlowSubscriber when the subscription ended because the
+// subscriber fell behind, and nil otherwise
+func (s *Subscription) Err() error {
+	s.n.mu.Lock()
+	defer s.n.mu.Unlock()
+
+	return s.err
+}
+
+// Unsubscribe ends the subscription. The events not received yet stay on
+// the channel.
+func (s *Subscription) Unsubscribe() {
+	s.n.mu.Lock()
+	defer s.n.mu.Unlock()
+
+	if _, ok := s.n.subs[s]; ok {
+		s.close()
+	}
+}
+
+// close closes the channel of a registered subscription. The caller holds
+// the notifier's mu.
+func (s *Subscription) close() {
+	delete(s.n.subs, s)
+	close(s.events)
+}
+
+// Notifier returns the notifier of the blockchain. The chain publishes the
+// block events and NewTip on it; the node keeping the mempool publishes the
+// transaction events.
+func (bc *Blockchain) Notifier() *Notifier {
+	return &bc.notifier
+}
+
+// notifyChainChange publishes the events of a main chain change: the
+// disconnected blocks, tip first, then the connected blocks, parent first,
+// then the new tip
+func (bc *Blockchain) notifyChainChange(disconnected, connected []*Block, tipHash []byte, tipHeight int) {
+	var events []Event
+	for _, block := range disconnected {
+		events = append(events, Event{Type: BlockDisconnected, Hash: block.Hash, Height: block.Height, Block: block})
+	}
+	for _, block := range connected {
+		events = append(events, Event{Type: BlockConnected, Hash: block.Hash, Height: block.Height, Block: block})
+	}
+	events = append(events, Event{Type: NewTip, Hash: tipHash, Height: tipHeight})
+
+	bc.notifier.Publish(events...)
+}
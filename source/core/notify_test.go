@@ -0,0 +1,107 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+// receive returns the events waiting on the subscription
+func receive(s *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case event, ok := <-s.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func eventTypes(events []Event) []EventType {
+	var types []EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+// eventHashes returns the hashes of the events of the type
+func eventHashes(events []Event, eventType EventType) [][]byte {
+	var hashes [][]byte
+	for _, event := range events {
+		if event.Type == eventType {
+			hashes = append(hashes, event.Hash)
+		}
+	}
+
+	return hashes
+}
+
+func TestNotifier(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	minerA := string(w.GetAddress())
+	minerB := string(newWallet(t).GetAddress())
+	genesis := bc.Tip()
+
+	s := bc.Notifier().Subscribe(10)
+
+	a1 := mineBlock(t, bc, minerA, nil)
+	events := receive(s)
+	assert.Equal(t, []EventType{BlockConnected, NewTip}, eventTypes(events))
+	assert.Equal(t, a1.Hash, events[0].Block.Hash)
+	assert.Equal(t, a1.Hash, events[1].Hash)
+	assert.Equal(t, 1, events[1].Height)
+
+	a2 := mineBlock(t, bc, minerA, nil)
+	receive(s)
+
+	// A side branch reports nothing until it takes over. On equal work at
+	// b2 it may take over one block early.
+	b1 := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, minerB, "", params.Active.InitialSubsidy)}, genesis, 1, params.Active.PowLimitBits, blockTime(1))
+	b2 := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, minerB, "", params.Active.InitialSubsidy)}, b1.Hash, 2, params.Active.PowLimitBits, blockTime(2))
+	b3 := NewBlock([]*transaction.Transaction{newCoinbaseTX(t, minerB, "", params.Active.InitialSubsidy)}, b2.Hash, 3, params.Active.PowLimitBits, blockTime(3))
+	_, _, err := bc.AddBlock(b1)
+	assert.NoError(t, err)
+	assert.Empty(t, receive(s))
+
+	for _, block := range []*Block{b2, b3} {
+		_, _, err = bc.AddBlock(block)
+		assert.NoError(t, err)
+	}
+	events = receive(s)
+	assert.Equal(t, [][]byte{a2.Hash, a1.Hash}, eventHashes(events, BlockDisconnected))
+	assert.Equal(t, [][]byte{b1.Hash, b2.Hash, b3.Hash}, eventHashes(events, BlockConnected))
+	assert.Equal(t, Event{Type: NewTip, Hash: b3.Hash, Height: 3}, events[len(events)-1])
+
+	_, err = bc.RollbackTo(1)
+	assert.NoError(t, err)
+	events = receive(s)
+	assert.Equal(t, []EventType{BlockDisconnected, BlockDisconnected, NewTip}, eventTypes(events))
+	assert.Equal(t, [][]byte{b3.Hash, b2.Hash}, eventHashes(events, BlockDisconnected))
+	assert.Equal(t, Event{Type: NewTip, Hash: b1.Hash, Height: 1}, events[2])
+
+	// A subscriber that falls behind is dropped instead of holding the chain
+	slow := bc.Notifier().Subscribe(1)
+	_, _, err = bc.AddBlock(b2)
+	assert.NoError(t, err)
+	assert.Len(t, receive(slow), 1)
+	assert.Equal(t, ErrSlowSubscriber, slow.Err())
+	assert.Len(t, receive(s), 2)
+	assert.NoError(t, s.Err())
+
+	s.Unsubscribe()
+	_, _, err = bc.AddBlock(b3)
+	assert.NoError(t, err)
+	_, ok := <-s.Events()
+	assert.False(t, ok)
+	assert.NoError(t, s.Err())
+}
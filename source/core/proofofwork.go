@@ -0,0 +1,126 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+var (
+	maxNonce = math.MaxInt64
+)
+
+// ProofOfWork represents a proof-of-work
+type ProofOfWork struct {
+	header *BlockHeader
+	target *big.Int
+}
+
+// NewProofOfWork builds and returns a ProofOfWork for the target stored in
+// the block header
+func NewProofOfWork(h *BlockHeader) *ProofOfWork {
+	target := params.CompactToBig(h.Bits)
+
+	pow := &ProofOfWork{h, target}
+
+	return pow
+}
+
+// prepareData returns the data the hash of a header is taken over: Version,
+// Timestamp, Bits, the nonce and Height as fixed-width big-endian int64s, in
+// header order, with PrevBlockHash and MerkleRoot in between as they are.
+// Both hashes have 32 bytes, except the empty PrevBlockHash of the genesis
+// block.
+func (pow *ProofOfWork) prepareData(nonce int) []byte {
+	data := bytes.Join(
+		[][]byte{
+			utils.IntToHex(int64(pow.header.Version)),
+			pow.header.PrevBlockHash,
+			pow.header.MerkleRoot,
+			utils.IntToHex(pow.header.Timestamp),
+			utils.IntToHex(int64(pow.header.Bits)),
+			utils.IntToHex(int64(nonce)),
+			utils.IntToHex(int64(pow.header.Height)),
+		},
+		[]byte{},
+	)
+
+	return data
+}
+
+// Run performs a proof-of-work
+func (pow *ProofOfWork) Run() (int, []byte) {
+	var hashInt big.Int
+	var hash [32]byte
+	nonce := 0
+
+	fmt.Printf("Mining a new block")
+	for nonce < maxNonce {
+		data := pow.prepareData(nonce)
+
+		hash = sha256.Sum256(data)
+		if math.Remainder(float64(nonce), 100000) == 0 {
+			fmt.Printf("\r%x", hash)
+		}
+		hashInt.SetBytes(hash[:])
+
+		if hashInt.Cmp(pow.target) == -1 {
+			break
+		} else {
+			nonce++
+		}
+	}
+	fmt.Print("\n\n")
+
+	return nonce, hash[:]
+}
+
+// Validate validates block's PoW
+func (pow *ProofOfWork) Validate() bool {
+	var hashInt big.Int
+
+	hashInt.SetBytes(pow.header.Hash())
+
+	isValid := pow.target.Sign() > 0 && pow.target.Cmp(params.Active.PowLimit()) <= 0 && hashInt.Cmp(pow.target) == -1
+
+	return isValid
+}
+
+// Work returns the expected number of hashes needed to meet the header's
+// target, 2^256 / (target + 1)
+func (pow *ProofOfWork) Work() *big.Int {
+	denominator := new(big.Int).Add(pow.target, big.NewInt(1))
+	numerator := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	return numerator.Div(numerator, denominator)
+}
+
+// retarget scales the target of the last interval by how long the interval
+// actually took compared to the expected timespan. The adjustment is limited
+// to a factor of four in either direction and never exceeds the network's
+// PowLimit.
+func retarget(bits uint32, actualTimespan int64) uint32 {
+	targetTimespan := int64(params.Active.RetargetInterval) * params.Active.TargetBlockTime
+
+	if actualTimespan < targetTimespan/4 {
+		actualTimespan = targetTimespan / 4
+	}
+	if actualTimespan > targetTimespan*4 {
+		actualTimespan = targetTimespan * 4
+	}
+
+	target := params.CompactToBig(bits)
+	target.Mul(target, big.NewInt(actualTimespan))
+	target.Div(target, big.NewInt(targetTimespan))
+
+	if powLimit := params.Active.PowLimit(); target.Cmp(powLimit) > 0 {
+		target = powLimit
+	}
+
+	return params.BigToCompact(target)
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+)
+
+func TestRetarget(t *testing.T) {
+	timespan := int64(params.Active.RetargetInterval) * params.Active.TargetBlockTime
+	bits := uint32(0x1e010000)
+	target := params.CompactToBig(bits)
+
+	tests := []struct {
+		actual int64
+		want   *big.Int
+	}{
+		{timespan, target},
+		{timespan / 2, new(big.Int).Div(target, big.NewInt(2))},
+		{timespan * 2, new(big.Int).Mul(target, big.NewInt(2))},
+		{0, new(big.Int).Div(target, big.NewInt(4))},
+		{timespan * 100, new(big.Int).Mul(target, big.NewInt(4))},
+	}
+
+	for _, test := range tests {
+		got := params.CompactToBig(retarget(bits, test.actual))
+		assert.Equal(t, 0, got.Cmp(test.want), fmt.Sprintf("actual timespan %d", test.actual))
+	}
+
+	assert.Equal(t, params.Active.PowLimitBits, retarget(params.Active.PowLimitBits, timespan*4), "targets never exceed powLimit")
+}
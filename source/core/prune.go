@@ -0,0 +1,104 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+// pruneBucket records the height up to which main chain blocks were pruned
+const pruneBucket = "prune"
+
+// MinPruneDepth is the smallest number of recent blocks a pruned node keeps.
+// Reorganizations can't go deeper than the prune depth.
+const MinPruneDepth = 10
+
+var prunedHeightKey = []byte("h")
+
+// ErrPrunedReorganize is returned when a branch with more work forks off the
+// main chain below the pruned height, so the main chain can't be disconnected
+var ErrPrunedReorganize = errors.New("the reorganization would disconnect pruned blocks")
+
+// ErrPruned is returned by operations that need the transactions of every
+// block when some were pruned
+var ErrPruned = errors.New("the operation needs every block, but some were pruned")
+
+// IsPruned checks whether any blocks of the chain were pruned
+func (bc *Blockchain) IsPruned() (bool, error) {
+	pruned := false
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		p := tx.Bucket([]byte(pruneBucket))
+		pruned = p != nil && p.Get(prunedHeightKey) != nil
+
+		return nil
+	})
+
+	return pruned, err
+}
+
+// Prune drops the transactions of the main chain blocks buried more than depth
+// blocks deep, along with their undo data and transaction index entries. Only
+// the header fields of such blocks are kept. The number of newly pruned
+// blocks is returned.
+func (bc *Blockchain) Prune(depth int) (int, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	counter := 0
+
+	err := bc.db.Update(func(tx storage.Tx) error {
+		p, err := tx.CreateBucketIfNotExists([]byte(pruneBucket))
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket([]byte(blocksBucket))
+		h := tx.Bucket([]byte(heightIndexBucket))
+		t := tx.Bucket([]byte(txIndexBucket))
+		ud := tx.Bucket([]byte(undoBucket))
+
+		start := 0
+		if data := p.Get(prunedHeightKey); data != nil {
+			start = int(utils.HexToInt(data)) + 1
+		}
+		tip, err := DeserializeBlockHeader(tx.Bucket([]byte(headersBucket)).Get(bc.tip))
+		if err != nil {
+			return err
+		}
+		end := tip.Height - depth
+
+		for height := start; height <= end; height++ {
+			hash := h.Get(utils.IntToHex(int64(height)))
+			block, err := DeserializeBlock(b.Get(hash))
+			if err != nil {
+				return err
+			}
+
+			if err := unindexTransactions(t, block); err != nil {
+				return err
+			}
+			if err := ud.Delete(block.Hash); err != nil {
+				return err
+			}
+
+			block.Transactions = nil
+			if err := b.Put(block.Hash, block.Serialize()); err != nil {
+				return err
+			}
+			counter++
+		}
+
+		if end >= start {
+			return p.Put(prunedHeightKey, utils.IntToHex(int64(end)))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
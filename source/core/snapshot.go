@@ -0,0 +1,504 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+// A UTXO snapshot file holds:
+//
+//	magic         bytes, snapshotMagic
+//	version       uint32, snapshotVersion
+//	tip           bytes, the hash of the main chain block the snapshot is at
+//	len(headers)  uvarint
+//	headers       the header of every main chain block from the genesis block
+//	              to the tip, each as bytes holding BlockHeader.Serialize
+//	content hash  bytes, see utxoContentHash
+//	len(UTXO)     uvarint
+//	UTXO          the entries of the chainstate bucket in key order, each as
+//	              the transaction ID and the TXOutputs encoding as bytes
+//
+// The headers let a new node follow the chain from the tip of the snapshot
+// before it has the blocks below it.
+
+var snapshotMagic = []byte("utxo-snapshot")
+
+const snapshotVersion = 1
+
+// snapshotUTXOBucket holds the UTXO set replayed from the historical blocks
+// while a loaded snapshot is validated
+const snapshotUTXOBucket = "snapshotutxo"
+
+// Keys of the meta bucket tracking the validation of a loaded snapshot: the
+// height of the snapshot, its content hash and the height up to which the
+// historical blocks were validated. They are removed once the snapshot is
+// found valid.
+var (
+	snapshotHeightKey    = []byte("snapshot")
+	snapshotContentKey   = []byte("snapshotcontent")
+	snapshotValidatedKey = []byte("snapshotvalidated")
+)
+
+// ErrBadSnapshotFile is returned when loading a file that isn't a UTXO
+// snapshot of the network's blockchain
+var ErrBadSnapshotFile = errors.New("not a UTXO snapshot of this network's blockchain")
+
+// ErrUnknownSnapshot is returned when loading a UTXO snapshot the network
+// parameters don't pin
+var ErrUnknownSnapshot = errors.New("the UTXO snapshot is not pinned by the network")
+
+// ErrSnapshotMismatch is returned when the unspent outputs of a UTXO snapshot
+// don't hash to the pinned content hash, or the historical blocks don't
+// leave the UTXO set the snapshot holds
+var ErrSnapshotMismatch = errors.New("the UTXO set does not match the snapshot")
+
+// ErrNotFresh is returned when loading a UTXO snapshot into a blockchain that
+// already has blocks past the genesis block
+var ErrNotFresh = errors.New("a UTXO snapshot can only be loaded into a blockchain holding just the genesis block")
+
+// utxoContentHash returns the SHA-256 of the entries of a UTXO bucket in key
+// order, each written as in a snapshot file, along with their number
+func utxoContentHash(b storage.Bucket) ([]byte, int) {
+	hash := sha256.New()
+	count := 0
+
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		hash.Write(snapshotEntry(k, v))
+		count++
+	}
+
+	return hash.Sum(nil), count
+}
+
+// snapshotEntry encodes an entry of the chainstate bucket as written in a
+// snapshot file
+func snapshotEntry(txID, outs []byte) []byte {
+	var w utils.Writer
+	w.VarBytes(txID)
+	w.VarBytes(outs)
+
+	return w.Data()
+}
+
+// DumpUTXO writes a snapshot of the UTXO set at the main chain tip to w, in
+// the format described above. The snapshot is returned as the network
+// parameters pin it.
+func (bc *Blockchain) DumpUTXO(w io.Writer) (params.UTXOSnapshot, error) {
+	var snapshot params.UTXOSnapshot
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		h := tx.Bucket([]byte(headersBucket))
+		heights := tx.Bucket([]byte(heightIndexBucket))
+		u := tx.Bucket([]byte(utxoBucket))
+
+		tipHash := tx.Bucket([]byte(blocksBucket)).Get([]byte("l"))
+		tip, err := DeserializeBlockHeader(h.Get(tipHash))
+		if err != nil {
+			return err
+		}
+		contentHash, count := utxoContentHash(u)
+
+		var header utils.Writer
+		header.VarBytes(snapshotMagic)
+		header.Uint32(snapshotVersion)
+		header.VarBytes(tipHash)
+		header.Uvarint(uint64(tip.Height + 1))
+		if _, err := w.Write(header.Data()); err != nil {
+			return err
+		}
+
+		for height := 0; height <= tip.Height; height++ {
+			var record utils.Writer
+			record.VarBytes(h.Get(heights.Get(utils.IntToHex(int64(height)))))
+			if _, err := w.Write(record.Data()); err != nil {
+				return err
+			}
+		}
+
+		var content utils.Writer
+		content.VarBytes(contentHash)
+		content.Uvarint(uint64(count))
+		if _, err := w.Write(content.Data()); err != nil {
+			return err
+		}
+
+		c := u.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if _, err := w.Write(snapshotEntry(k, v)); err != nil {
+				return err
+			}
+		}
+
+		snapshot = params.UTXOSnapshot{Height: tip.Height, BlockHash: hex.EncodeToString(tipHash), ContentHash: hex.EncodeToString(contentHash)}
+
+		return nil
+	})
+
+	return snapshot, err
+}
+
+// pinnedSnapshot returns the UTXO snapshot the network pins at the block
+func pinnedSnapshot(blockHash []byte) (params.UTXOSnapshot, bool) {
+	for _, snapshot := range params.Active.UTXOSnapshots {
+		if snapshot.BlockHash == hex.EncodeToString(blockHash) {
+			return snapshot, true
+		}
+	}
+
+	return params.UTXOSnapshot{}, false
+}
+
+// LoadUTXOSnapshot reads a UTXO snapshot file from r into a blockchain that
+// holds just the genesis block. The snapshot must be pinned by the network
+// parameters. Its headers are validated and become the main chain, with the
+// UTXO set of the snapshot as the chainstate. The blocks below the tip of
+// the snapshot are treated as pruned until ValidateSnapshotBlock has
+// replayed them all and found the same UTXO set. Nothing is changed when an
+// error is returned.
+func (bc *Blockchain) LoadUTXOSnapshot(r io.Reader) (params.UTXOSnapshot, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	br := bufio.NewReader(r)
+
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return params.UTXOSnapshot{}, err
+	}
+	if bestHeight != 0 {
+		return params.UTXOSnapshot{}, ErrNotFresh
+	}
+
+	if err := readFileHeader(br, snapshotMagic, snapshotVersion); err != nil {
+		return params.UTXOSnapshot{}, fmt.Errorf("%w: %v", ErrBadSnapshotFile, err)
+	}
+	tipHash, err := readRecord(br)
+	if err != nil {
+		return params.UTXOSnapshot{}, fmt.Errorf("%w: %v", ErrBadSnapshotFile, noEOF(err))
+	}
+	snapshot, ok := pinnedSnapshot(tipHash)
+	if !ok {
+		return params.UTXOSnapshot{}, fmt.Errorf("%w: it is at block %x", ErrUnknownSnapshot, tipHash)
+	}
+
+	headers, err := bc.readSnapshotHeaders(br, snapshot)
+	if err != nil {
+		return params.UTXOSnapshot{}, fmt.Errorf("%w: %v", ErrBadSnapshotFile, err)
+	}
+
+	contentHash, err := readRecord(br)
+	if err != nil {
+		return params.UTXOSnapshot{}, fmt.Errorf("%w: %v", ErrBadSnapshotFile, noEOF(err))
+	}
+	if hex.EncodeToString(contentHash) != snapshot.ContentHash {
+		return params.UTXOSnapshot{}, fmt.Errorf("%w: content hash %x, pinned %s", ErrSnapshotMismatch, contentHash, snapshot.ContentHash)
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return params.UTXOSnapshot{}, fmt.Errorf("%w: %v", ErrBadSnapshotFile, noEOF(err))
+	}
+
+	err = bc.db.Update(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		h := tx.Bucket([]byte(headersBucket))
+		heights := tx.Bucket([]byte(heightIndexBucket))
+
+		genesis, err := DeserializeBlock(b.Get(heights.Get(utils.IntToHex(0))))
+		if err != nil {
+			return err
+		}
+
+		for _, header := range headers[1:] {
+			hash := header.Hash()
+			if err := h.Put(hash, header.Serialize()); err != nil {
+				return err
+			}
+			if err := b.Put(hash, (&Block{BlockHeader: *header}).Serialize()); err != nil {
+				return err
+			}
+			if err := heights.Put(utils.IntToHex(int64(header.Height)), hash); err != nil {
+				return err
+			}
+		}
+		if err := b.Put([]byte("l"), tipHash); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteBucket([]byte(utxoBucket)); err != nil {
+			return err
+		}
+		u, err := tx.CreateBucket([]byte(utxoBucket))
+		if err != nil {
+			return err
+		}
+		if err := readSnapshotUTXO(br, u, count, contentHash); err != nil {
+			return err
+		}
+
+		// A snapshot of the genesis block has no historical blocks to check
+		if snapshot.Height == 0 {
+			return nil
+		}
+
+		s, err := tx.CreateBucket([]byte(snapshotUTXOBucket))
+		if err != nil {
+			return err
+		}
+		if err := connectGenesis(s, genesis); err != nil {
+			return err
+		}
+
+		m := tx.Bucket([]byte(metaBucket))
+		if err := m.Put(snapshotHeightKey, utils.IntToHex(int64(snapshot.Height))); err != nil {
+			return err
+		}
+		if err := m.Put(snapshotContentKey, contentHash); err != nil {
+			return err
+		}
+		if err := m.Put(snapshotValidatedKey, utils.IntToHex(0)); err != nil {
+			return err
+		}
+
+		// The blocks below the tip have no transactions yet, just like
+		// pruned ones
+		p, err := tx.CreateBucketIfNotExists([]byte(pruneBucket))
+		if err != nil {
+			return err
+		}
+
+		return p.Put(prunedHeightKey, utils.IntToHex(int64(snapshot.Height)))
+	})
+	if err != nil {
+		return params.UTXOSnapshot{}, err
+	}
+	bc.setTip(tipHash)
+	bc.notifyChainChange(nil, nil, tipHash, snapshot.Height)
+
+	return snapshot, nil
+}
+
+// readSnapshotHeaders reads the headers of a snapshot file and validates
+// them as a chain starting with the genesis block and ending with the
+// pinned block. They are checked against each other in memory, so nothing
+// is stored before the whole chain is known to be valid.
+func (bc *Blockchain) readSnapshotHeaders(r *bufio.Reader, snapshot params.UTXOSnapshot) ([]*BlockHeader, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	if count != uint64(snapshot.Height+1) {
+		return nil, fmt.Errorf("%d headers for a snapshot at height %d", count, snapshot.Height)
+	}
+
+	genesisHash, err := bc.GetBlockHash(0)
+	if err != nil {
+		return nil, err
+	}
+
+	staging := &Blockchain{db: storage.NewMemory()}
+	defer staging.db.Close()
+	err = staging.db.Update(func(tx storage.Tx) error {
+		_, err := tx.CreateBucket([]byte(headersBucket))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make([]*BlockHeader, 0, count)
+	var parent *BlockHeader
+	for i := 0; i <= snapshot.Height; i++ {
+		data, err := readRecord(r)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		header, err := DeserializeBlockHeader(data)
+		if err != nil {
+			return nil, err
+		}
+		hash := header.Hash()
+
+		if parent == nil && !bytes.Equal(hash, genesisHash) {
+			return nil, fmt.Errorf("it starts with block %x instead of the genesis block", hash)
+		}
+		if err := staging.verifyHeader(&Block{BlockHeader: *header, Hash: hash}, hash, parent, false); err != nil {
+			return nil, fmt.Errorf("header at height %d: %w", i, err)
+		}
+
+		err = staging.db.Update(func(tx storage.Tx) error {
+			return tx.Bucket([]byte(headersBucket)).Put(hash, data)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		headers = append(headers, header)
+		parent = header
+	}
+
+	if hex.EncodeToString(parent.Hash()) != snapshot.BlockHash {
+		return nil, fmt.Errorf("the headers end with block %x", parent.Hash())
+	}
+
+	return headers, nil
+}
+
+// readSnapshotUTXO reads the entries of a snapshot file into the UTXO bucket
+// and checks that they hash to contentHash
+func readSnapshotUTXO(r *bufio.Reader, u storage.Bucket, count uint64, contentHash []byte) error {
+	hash := sha256.New()
+	var prev []byte
+
+	for i := uint64(0); i < count; i++ {
+		txID, err := readRecord(r)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBadSnapshotFile, noEOF(err))
+		}
+		outs, err := readRecord(r)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBadSnapshotFile, noEOF(err))
+		}
+
+		if prev != nil && bytes.Compare(txID, prev) <= 0 {
+			return fmt.Errorf("%w: the unspent outputs are not in key order", ErrBadSnapshotFile)
+		}
+		if _, err := transaction.DeserializeOutputs(outs); err != nil {
+			return fmt.Errorf("%w: %v", ErrBadSnapshotFile, err)
+		}
+
+		hash.Write(snapshotEntry(txID, outs))
+		if err := u.Put(txID, outs); err != nil {
+			return err
+		}
+		prev = txID
+	}
+
+	if _, err := r.ReadByte(); err != io.EOF {
+		return fmt.Errorf("%w: data after the unspent outputs", ErrBadSnapshotFile)
+	}
+	if !bytes.Equal(hash.Sum(nil), contentHash) {
+		return fmt.Errorf("%w: the unspent outputs don't hash to %x", ErrSnapshotMismatch, contentHash)
+	}
+
+	return nil
+}
+
+// NextSnapshotBlock returns the hash of the historical block the validation
+// of a loaded UTXO snapshot needs next, or nil when there is no snapshot
+// left to validate
+func (bc *Blockchain) NextSnapshotBlock() ([]byte, error) {
+	var hash []byte
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		m := tx.Bucket([]byte(metaBucket))
+		if m == nil || m.Get(snapshotHeightKey) == nil {
+			return nil
+		}
+
+		next := utils.HexToInt(m.Get(snapshotValidatedKey)) + 1
+		if data := tx.Bucket([]byte(heightIndexBucket)).Get(utils.IntToHex(next)); data != nil {
+			hash = append([]byte{}, data...)
+		}
+
+		return nil
+	})
+
+	return hash, err
+}
+
+// ValidateSnapshotBlock validates the historical block NextSnapshotBlock
+// asked for and stores it in full. Its transactions are replayed on a UTXO
+// set of their own; once the block at the height of the snapshot is
+// replayed, that UTXO set must hash to the content hash of the snapshot, or
+// ErrSnapshotMismatch is returned. done tells whether the snapshot was found
+// valid, after which the blocks below it are no longer treated as pruned
+// unless the node prunes them itself.
+func (bc *Blockchain) ValidateSnapshotBlock(block *Block) (done bool, err error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if err := checkBlock(block); err != nil {
+		return false, err
+	}
+
+	err = bc.db.Update(func(tx storage.Tx) error {
+		m := tx.Bucket([]byte(metaBucket))
+		if m == nil || m.Get(snapshotHeightKey) == nil {
+			return fmt.Errorf("block %x: there is no UTXO snapshot to validate", block.Hash)
+		}
+		snapshotHeight := int(utils.HexToInt(m.Get(snapshotHeightKey)))
+		height := int(utils.HexToInt(m.Get(snapshotValidatedKey))) + 1
+
+		expected := tx.Bucket([]byte(heightIndexBucket)).Get(utils.IntToHex(int64(height)))
+		if !bytes.Equal(expected, block.Hash) {
+			return fmt.Errorf("block %x isn't the main chain block at height %d the snapshot validation needs", block.Hash, height)
+		}
+
+		s := tx.Bucket([]byte(snapshotUTXOBucket))
+		undo, err := connectBlock(s, block, checksSignatures(tx, block))
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket([]byte(undoBucket)).Put(block.Hash, undo.Serialize()); err != nil {
+			return err
+		}
+		if err := indexTransactions(tx.Bucket([]byte(txIndexBucket)), block); err != nil {
+			return err
+		}
+		if a := tx.Bucket([]byte(addrIndexBucket)); a != nil {
+			if _, err := indexAddresses(a, block, undo); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket([]byte(blocksBucket)).Put(block.Hash, block.Serialize()); err != nil {
+			return err
+		}
+		if err := m.Put(snapshotValidatedKey, utils.IntToHex(int64(height))); err != nil {
+			return err
+		}
+
+		if height < snapshotHeight {
+			return nil
+		}
+
+		contentHash, _ := utxoContentHash(s)
+		if !bytes.Equal(contentHash, m.Get(snapshotContentKey)) {
+			return fmt.Errorf("%w: the historical blocks leave a UTXO set hashing to %x", ErrSnapshotMismatch, contentHash)
+		}
+
+		if err := tx.DeleteBucket([]byte(snapshotUTXOBucket)); err != nil {
+			return err
+		}
+		for _, key := range [][]byte{snapshotHeightKey, snapshotContentKey, snapshotValidatedKey} {
+			if err := m.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		// A node pruning on its own has moved the prune height past the
+		// snapshot
+		p := tx.Bucket([]byte(pruneBucket))
+		if data := p.Get(prunedHeightKey); data != nil && int(utils.HexToInt(data)) == snapshotHeight {
+			if err := p.Delete(prunedHeightKey); err != nil {
+				return err
+			}
+		}
+		done = true
+
+		return nil
+	})
+
+	return done, err
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+// validateSnapshot feeds the historical blocks of source to bc until the
+// snapshot validation is done or fails
+func validateSnapshot(t *testing.T, bc, source *Blockchain) error {
+	for {
+		next, err := bc.NextSnapshotBlock()
+		if err != nil || next == nil {
+			return err
+		}
+
+		block, err := source.GetBlock(next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bc.ValidateSnapshotBlock(&block); err != nil {
+			return err
+		}
+	}
+}
+
+func TestUTXOSnapshot(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+	to := string(newWallet(t).GetAddress())
+
+	mineBlock(t, bc, address, []*transaction.Transaction{newTransaction(t, bc, w, to, 3, 1)})
+	mineBlock(t, bc, address, nil)
+
+	var file bytes.Buffer
+	snapshot, err := bc.DumpUTXO(&file)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, snapshot.Height)
+
+	fresh, err := OpenBlockchain(storage.NewMemory())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer fresh.Close()
+
+	_, err = fresh.LoadUTXOSnapshot(bytes.NewReader(file.Bytes()))
+	assert.True(t, errors.Is(err, ErrUnknownSnapshot))
+
+	params.Active.UTXOSnapshots = []params.UTXOSnapshot{{Height: snapshot.Height, BlockHash: snapshot.BlockHash, ContentHash: "00"}}
+	_, err = fresh.LoadUTXOSnapshot(bytes.NewReader(file.Bytes()))
+	assert.True(t, errors.Is(err, ErrSnapshotMismatch))
+	assert.Equal(t, 0, bestHeight(t, fresh), "a refused snapshot changes nothing")
+
+	params.Active.UTXOSnapshots = []params.UTXOSnapshot{snapshot}
+	_, err = bc.LoadUTXOSnapshot(bytes.NewReader(file.Bytes()))
+	assert.Equal(t, ErrNotFresh, err)
+
+	loaded, err := fresh.LoadUTXOSnapshot(bytes.NewReader(file.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, loaded)
+	assert.Equal(t, bc.Tip(), fresh.Tip())
+	assert.Equal(t, 3, balance(t, fresh, to))
+	pruned, _ := fresh.IsPruned()
+	assert.True(t, pruned, "the historical blocks are missing")
+
+	// The node follows the chain from the snapshot on
+	mineBlock(t, fresh, address, []*transaction.Transaction{newTransaction(t, fresh, w, to, 2, 0)})
+	assert.Equal(t, 5, balance(t, fresh, to))
+
+	assert.NoError(t, validateSnapshot(t, fresh, bc))
+	next, err := fresh.NextSnapshotBlock()
+	assert.NoError(t, err)
+	assert.Nil(t, next)
+	pruned, _ = fresh.IsPruned()
+	assert.False(t, pruned)
+
+	_, err = fresh.VerifyChain(VerifyUTXO)
+	assert.NoError(t, err)
+	_, err = fresh.FindTransaction(bc.Tip())
+	assert.Error(t, err)
+	block, _ := bc.GetBlockByHeight(1)
+	_, err = fresh.FindTransaction(block.Transactions[1].ID)
+	assert.NoError(t, err, "the historical transactions are indexed")
+}
+
+func TestUTXOSnapshotMismatch(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	tx := newTransaction(t, bc, w, string(newWallet(t).GetAddress()), 3, 1)
+	mineBlock(t, bc, string(w.GetAddress()), []*transaction.Transaction{tx})
+
+	// A snapshot of a doctored UTXO set, pinned by mistake
+	bc.db.Update(func(dbTx storage.Tx) error {
+		return dbTx.Bucket([]byte(utxoBucket)).Delete(tx.ID)
+	})
+	var file bytes.Buffer
+	snapshot, err := bc.DumpUTXO(&file)
+	assert.NoError(t, err)
+	params.Active.UTXOSnapshots = []params.UTXOSnapshot{snapshot}
+
+	fresh, err := OpenBlockchain(storage.NewMemory())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer fresh.Close()
+
+	_, err = fresh.LoadUTXOSnapshot(&file)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(validateSnapshot(t, fresh, bc), ErrSnapshotMismatch), "the historical blocks give it away")
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// medianTimeSpan is the number of blocks the median time past is taken over
+	medianTimeSpan = 11
+	// maxFutureBlockTime is how far ahead of the network-adjusted time a block
+	// timestamp may be, in seconds
+	maxFutureBlockTime = 2 * 60 * 60
+	// maxTimeAdjustment bounds the offset applied to the local clock. Peers
+	// disagreeing by more than that hint at a wrong local clock, which is
+	// reported instead.
+	maxTimeAdjustment = 70 * 60
+	// maxTimeSamples is the number of peers whose clock offset is kept
+	maxTimeSamples = 200
+)
+
+// timeData holds the offsets between the clocks of the peers and the local
+// clock. The zero value holds none.
+type timeData struct {
+	mu      sync.Mutex
+	offsets map[string]int64
+}
+
+// AddTimeSample records the offset between the clock of a peer, as reported
+// in its version message, and the local clock. Peers are told apart by the
+// host they connect from, so one peer can't claim several samples, and only
+// the first sample of each counts.
+func (bc *Blockchain) AddTimeSample(host string, peerTime int64) {
+	t := &bc.times
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.offsets == nil {
+		t.offsets = make(map[string]int64)
+	}
+	if _, ok := t.offsets[host]; ok || len(t.offsets) >= maxTimeSamples {
+		return
+	}
+	t.offsets[host] = peerTime - time.Now().Unix()
+
+	if offset := t.medianOffset(); offset > maxTimeAdjustment || offset < -maxTimeAdjustment {
+		fmt.Printf("WARNING: Peers' clocks are %d seconds off, please check the local clock\n", offset)
+	}
+}
+
+// medianOffset returns the median of the peer offsets and our own zero
+// offset. mu must be held.
+func (t *timeData) medianOffset() int64 {
+	offsets := []int64{0}
+	for _, offset := range t.offsets {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	return offsets[len(offsets)/2]
+}
+
+// adjustedTime returns the local time corrected by the median offset of the
+// peers' clocks. Offsets beyond maxTimeAdjustment are not applied.
+func (bc *Blockchain) adjustedTime() int64 {
+	t := &bc.times
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	offset := t.medianOffset()
+	if offset > maxTimeAdjustment || offset < -maxTimeAdjustment {
+		offset = 0
+	}
+
+	return time.Now().Unix() + offset
+}
+
+// medianTimePast returns the median timestamp of the block of the header and
+// the blocks preceding it, up to medianTimeSpan blocks
+func (bc *Blockchain) medianTimePast(header *BlockHeader) int64 {
+	var timestamps []int64
+
+	for i := 0; i < medianTimeSpan; i++ {
+		timestamps = append(timestamps, header.Timestamp)
+		if len(header.PrevBlockHash) == 0 {
+			break
+		}
+
+		var err error
+		if header, err = bc.getParentHeader(header); err != nil {
+			break
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	return timestamps[len(timestamps)/2]
+}
+
+// nextTimestamp returns the timestamp for a block mined on top of parent: the
+// network-adjusted time, moved past the median time past if needed
+func (bc *Blockchain) nextTimestamp(parent *BlockHeader) int64 {
+	timestamp := bc.adjustedTime()
+	if mtp := bc.medianTimePast(parent); timestamp <= mtp {
+		timestamp = mtp + 1
+	}
+
+	return timestamp
+}
@@ -0,0 +1,25 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdjustedTime(t *testing.T) {
+	bc, _ := newTestBlockchain(t)
+	other, _ := newTestBlockchain(t)
+	now := time.Now().Unix()
+
+	bc.AddTimeSample("a", now+100)
+	bc.AddTimeSample("b", now+110)
+	bc.AddTimeSample("a", now-1000)
+	assert.InDelta(t, now+100, bc.adjustedTime(), 1, "the median of 0, 100 and 110 is applied")
+	assert.InDelta(t, now, other.adjustedTime(), 1, "each node keeps its own samples")
+
+	bc.AddTimeSample("c", now+maxTimeAdjustment*2)
+	bc.AddTimeSample("d", now+maxTimeAdjustment*2)
+	bc.AddTimeSample("e", now+maxTimeAdjustment*2)
+	assert.InDelta(t, now, bc.adjustedTime(), 1, "large offsets are not applied")
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+// ErrInsufficientFunds is returned when a wallet's spendable outputs don't
+// cover the amount to send and the fee
+var ErrInsufficientFunds = errors.New("not enough funds")
+
+// ErrBadAmount is returned when the amounts of a transaction are out of
+// range, or its outputs exceed its inputs
+var ErrBadAmount = errors.New("amounts out of range or outputs exceed inputs")
+
+// ErrImmatureSpend is returned when an input spends a coinbase output before
+// it matured
+var ErrImmatureSpend = errors.New("input spends an immature coinbase output")
+
+// blockSubsidy returns the amount of new coins a block at the given height
+// creates. It starts at the network's InitialSubsidy and halves every
+// SubsidyHalvingInterval blocks until it reaches zero.
+func blockSubsidy(height int) int {
+	halvings := uint(height / params.Active.SubsidyHalvingInterval)
+	if halvings >= 64 {
+		return 0
+	}
+
+	return params.Active.InitialSubsidy >> halvings
+}
+
+// NewUTXOTransaction creates a new transaction sending amount to the address
+// and leaving fee to the miner of the block that includes it.
+// ErrInsufficientFunds is returned when the wallet can't pay amount+fee.
+func NewUTXOTransaction(w *wallet.Wallet, to string, amount, fee int, UTXOSet *UTXOSet) (*transaction.Transaction, error) {
+	var inputs []transaction.TXInput
+	var outputs []transaction.TXOutput
+
+	pubKeyHash := wallet.HashPubKey(w.PublicKey)
+	acc, validOutputs, err := UTXOSet.FindSpendableOutputs(pubKeyHash, amount+fee)
+	if err != nil {
+		return nil, err
+	}
+
+	if acc < amount+fee {
+		return nil, fmt.Errorf("%w: %d spendable, %d needed", ErrInsufficientFunds, acc, amount+fee)
+	}
+
+	// Build a list of inputs
+	for txid, outs := range validOutputs {
+		txID, err := hex.DecodeString(txid)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, out := range outs {
+			input := transaction.TXInput{Txid: txID, Vout: out, PubKey: w.PublicKey}
+			inputs = append(inputs, input)
+		}
+	}
+
+	// Build a list of outputs
+	from := fmt.Sprintf("%s", w.GetAddress())
+	outputs = append(outputs, *transaction.NewTXOutput(amount, to))
+	if acc > amount+fee {
+		outputs = append(outputs, *transaction.NewTXOutput(acc-amount-fee, from)) // a change
+	}
+
+	tx := transaction.Transaction{Vin: inputs, Vout: outputs}
+	tx.ID = tx.Hash()
+	if err := UTXOSet.Blockchain.SignTransaction(&tx, w.PrivateKey); err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
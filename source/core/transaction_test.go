@@ -0,0 +1,17 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+)
+
+func TestBlockSubsidy(t *testing.T) {
+	assert.Equal(t, params.Active.InitialSubsidy, blockSubsidy(0))
+	assert.Equal(t, params.Active.InitialSubsidy, blockSubsidy(params.Active.SubsidyHalvingInterval-1))
+	assert.Equal(t, params.Active.InitialSubsidy/2, blockSubsidy(params.Active.SubsidyHalvingInterval))
+	assert.Equal(t, params.Active.InitialSubsidy/4, blockSubsidy(2*params.Active.SubsidyHalvingInterval))
+	assert.Equal(t, 0, blockSubsidy(64*params.Active.SubsidyHalvingInterval))
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+const txIndexBucket = "txindex"
+
+// txLocation is where a main chain transaction is stored: the hash of its
+// block followed by its position in the block as a big-endian int64
+type txLocation []byte
+
+func newTxLocation(blockHash []byte, position int) txLocation {
+	return append(append([]byte{}, blockHash...), utils.IntToHex(int64(position))...)
+}
+
+// BlockHash returns the hash of the block containing the transaction
+func (l txLocation) BlockHash() []byte {
+	return l[:len(l)-8]
+}
+
+// Position returns the index of the transaction in its block
+func (l txLocation) Position() int {
+	return int(utils.HexToInt(l[len(l)-8:]))
+}
+
+// lookupTransaction finds a main chain transaction using the transaction
+// index bucket t and the blocks bucket b of the same DB transaction.
+// ErrTransactionNotFound is returned when it isn't indexed or its block was
+// pruned.
+func lookupTransaction(t, b storage.Bucket, ID []byte) (transaction.Transaction, error) {
+	location := txLocation(t.Get(ID))
+	if location == nil {
+		return transaction.Transaction{}, ErrTransactionNotFound
+	}
+
+	blockData := b.Get(location.BlockHash())
+	if blockData == nil {
+		return transaction.Transaction{}, ErrTransactionNotFound
+	}
+
+	block, err := DeserializeBlock(blockData)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	if block.IsPruned() {
+		return transaction.Transaction{}, ErrTransactionNotFound
+	}
+
+	return *block.Transactions[location.Position()], nil
+}
+
+// indexTransactions adds the transactions of a block connected to the main
+// chain to the index
+func indexTransactions(b storage.Bucket, block *Block) error {
+	for i, tx := range block.Transactions {
+		err := b.Put(tx.ID, newTxLocation(block.Hash, i))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unindexTransactions removes the transactions of a block disconnected from
+// the main chain from the index
+func unindexTransactions(b storage.Bucket, block *Block) error {
+	for _, tx := range block.Transactions {
+		err := b.Delete(tx.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReindexTransactions rebuilds the transaction index from the main chain and
+// returns the number of indexed transactions
+func (bc *Blockchain) ReindexTransactions() (int, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	bucketName := []byte(txIndexBucket)
+	counter := 0
+
+	err := bc.db.Update(func(tx storage.Tx) error {
+		err := tx.DeleteBucket(bucketName)
+		if err != nil && err != storage.ErrBucketNotFound {
+			return err
+		}
+
+		t, err := tx.CreateBucket(bucketName)
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket([]byte(blocksBucket))
+		for hash := bc.tip; len(hash) > 0; {
+			block, err := DeserializeBlock(b.Get(hash))
+			if err != nil {
+				return err
+			}
+			if err := indexTransactions(t, block); err != nil {
+				return err
+			}
+			counter += len(block.Transactions)
+
+			hash = block.PrevBlockHash
+		}
+
+		return nil
+	})
+
+	return counter, err
+}
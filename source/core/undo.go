@@ -0,0 +1,100 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+const undoBucket = "undo"
+
+// SpentOutput is an output spent by an input of a block, with the height and
+// origin of the transaction that created it
+type SpentOutput struct {
+	Txid     []byte
+	Vout     int
+	Output   transaction.TXOutput
+	Height   int
+	Coinbase bool
+}
+
+// BlockUndo holds what is needed to disconnect a block from the UTXO set:
+// the outputs its inputs spent, in the order the inputs appear in the block
+type BlockUndo struct {
+	Spent []SpentOutput
+}
+
+// Serialize returns the canonical encoding of the BlockUndo:
+//
+//	len(Spent) uvarint
+//	Spent      for each output: Txid bytes, Vout varint, Value varint,
+//	           PubKeyHash bytes, Height varint, Coinbase bool
+func (u BlockUndo) Serialize() []byte {
+	var w utils.Writer
+
+	w.Uvarint(uint64(len(u.Spent)))
+	for _, spent := range u.Spent {
+		w.VarBytes(spent.Txid)
+		w.Varint(int64(spent.Vout))
+		w.Varint(int64(spent.Output.Value))
+		w.VarBytes(spent.Output.PubKeyHash)
+		w.Varint(int64(spent.Height))
+		w.Bool(spent.Coinbase)
+	}
+
+	return w.Data()
+}
+
+// DeserializeBlockUndo decodes a BlockUndo from its canonical encoding
+func DeserializeBlockUndo(data []byte) (BlockUndo, error) {
+	var undo BlockUndo
+
+	r := utils.NewReader(data)
+	for n := r.Count(); n > 0; n-- {
+		undo.Spent = append(undo.Spent, SpentOutput{
+			Txid:     r.VarBytes(),
+			Vout:     r.Int(),
+			Output:   transaction.TXOutput{Value: r.Int(), PubKeyHash: r.VarBytes()},
+			Height:   r.Int(),
+			Coinbase: r.Bool(),
+		})
+	}
+
+	if err := r.Finish(); err != nil {
+		return BlockUndo{}, fmt.Errorf("undo data: %w", err)
+	}
+
+	return undo, nil
+}
+
+// loadBlockUndo returns the undo data of a main chain block. Blocks connected
+// before undo data was recorded get it rebuilt from the transaction index
+// bucket t and the blocks bucket b.
+func loadBlockUndo(ud, t, b storage.Bucket, block *Block) (BlockUndo, error) {
+	if data := ud.Get(block.Hash); data != nil {
+		return DeserializeBlockUndo(data)
+	}
+
+	var undo BlockUndo
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		for _, vin := range tx.Vin {
+			prevTX, err := lookupTransaction(t, b, vin.Txid)
+			if err != nil {
+				return undo, err
+			}
+			prevBlock, err := DeserializeBlock(b.Get(txLocation(t.Get(vin.Txid)).BlockHash()))
+			if err != nil {
+				return undo, err
+			}
+			undo.Spent = append(undo.Spent, SpentOutput{vin.Txid, vin.Vout, prevTX.Vout[vin.Vout], prevBlock.Height, prevTX.IsCoinbase()})
+		}
+	}
+
+	return undo, nil
+}
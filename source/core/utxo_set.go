@@ -0,0 +1,325 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+const utxoBucket = "chainstate"
+
+// UTXOSet represents UTXO set
+type UTXOSet struct {
+	Blockchain *Blockchain
+}
+
+// FindSpendableOutputs finds and returns unspent outputs to reference in
+// inputs. Immature coinbase outputs are left out.
+func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[string][]int, error) {
+	unspentOutputs := make(map[string][]int)
+	accumulated := 0
+	db := u.Blockchain.db
+
+	bestHeight, err := u.Blockchain.GetBestHeight()
+	if err != nil {
+		return 0, nil, err
+	}
+	spendHeight := bestHeight + 1
+
+	err = db.View(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			txID := hex.EncodeToString(k)
+			outs, err := transaction.DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+			if !outs.IsMature(spendHeight) {
+				continue
+			}
+
+			for i, out := range outs.Outputs {
+				if out.IsLockedWithKey(pubkeyHash) && accumulated < amount {
+					accumulated += out.Value
+					unspentOutputs[txID] = append(unspentOutputs[txID], i)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return accumulated, unspentOutputs, err
+}
+
+// FindUTXO finds UTXO for a public key hash
+func (u UTXOSet) FindUTXO(pubKeyHash []byte) ([]transaction.TXOutput, error) {
+	var UTXOs []transaction.TXOutput
+	db := u.Blockchain.db
+
+	err := db.View(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			outs, err := transaction.DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+
+			for _, out := range outs.Outputs {
+				if out.IsLockedWithKey(pubKeyHash) {
+					UTXOs = append(UTXOs, out)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return UTXOs, err
+}
+
+// Balance returns the value of the unspent outputs of a public key hash,
+// split into what the next block can spend and immature coinbase outputs
+func (u UTXOSet) Balance(pubKeyHash []byte) (spendable, immature int, err error) {
+	db := u.Blockchain.db
+
+	bestHeight, err := u.Blockchain.GetBestHeight()
+	if err != nil {
+		return 0, 0, err
+	}
+	spendHeight := bestHeight + 1
+
+	err = db.View(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			outs, err := transaction.DeserializeOutputs(v)
+			if err != nil {
+				return err
+			}
+
+			for _, out := range outs.Outputs {
+				if !out.IsLockedWithKey(pubKeyHash) {
+					continue
+				}
+
+				if outs.IsMature(spendHeight) {
+					spendable += out.Value
+				} else {
+					immature += out.Value
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return spendable, immature, err
+}
+
+// Fee returns the fee paid by a transaction: the value of the unspent outputs
+// it spends minus the value of the outputs it creates. ErrMissingInput is
+// returned when it spends an output that isn't in the UTXO set, and
+// ErrBadAmount when its amounts are out of range or it pays a negative fee.
+func (u UTXOSet) Fee(tx *transaction.Transaction) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	prevTXs, err := u.Blockchain.prevTransactions(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	inputs, outputs := 0, 0
+	var ok bool
+	for _, vin := range tx.Vin {
+		if inputs, ok = addMoney(inputs, prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.Vout].Value); !ok {
+			return 0, fmt.Errorf("%w: transaction %x inputs", ErrBadAmount, tx.ID)
+		}
+	}
+
+	for _, out := range tx.Vout {
+		if outputs, ok = addMoney(outputs, out.Value); !ok {
+			return 0, fmt.Errorf("%w: transaction %x outputs", ErrBadAmount, tx.ID)
+		}
+	}
+
+	if outputs > inputs {
+		return 0, fmt.Errorf("%w: transaction %x spends %d of %d", ErrBadAmount, tx.ID, outputs, inputs)
+	}
+
+	return inputs - outputs, nil
+}
+
+// CountTransactions returns the number of transactions in the UTXO set
+func (u UTXOSet) CountTransactions() (int, error) {
+	db := u.Blockchain.db
+	counter := 0
+
+	err := db.View(func(tx storage.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			counter++
+		}
+
+		return nil
+	})
+
+	return counter, err
+}
+
+// Reindex rebuilds the UTXO set
+func (u UTXOSet) Reindex() error {
+	u.Blockchain.mu.Lock()
+	defer u.Blockchain.mu.Unlock()
+
+	db := u.Blockchain.db
+	bucketName := []byte(utxoBucket)
+
+	UTXO, err := u.Blockchain.FindUTXO()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx storage.Tx) error {
+		err := tx.DeleteBucket(bucketName)
+		if err != nil && err != storage.ErrBucketNotFound {
+			return err
+		}
+
+		b, err := tx.CreateBucket(bucketName)
+		if err != nil {
+			return err
+		}
+
+		for txID, outs := range UTXO {
+			key, err := hex.DecodeString(txID)
+			if err != nil {
+				return err
+			}
+
+			err = b.Put(key, outs.Serialize())
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// connectBlock removes the outputs spent by the block from the UTXO bucket
+// and adds the outputs it creates. Each transaction is checked against the
+// bucket before it is applied, and the coinbase may claim no more than the
+// block subsidy plus the fees. The spent outputs are returned as the undo
+// data of the block. The caller must discard the DB transaction when an
+// error is returned.
+func connectBlock(b storage.Bucket, block *Block, verifySignatures bool) (BlockUndo, error) {
+	var undo BlockUndo
+	fees := 0
+
+	for _, tx := range block.Transactions {
+		fee, err := checkTransactionInputs(b, block, tx, verifySignatures)
+		if err != nil {
+			return undo, err
+		}
+		var ok bool
+		if fees, ok = addMoney(fees, fee); !ok {
+			return undo, rejectBlock(block, RejectBadCoinbaseValue, "fees out of range")
+		}
+
+		if tx.IsCoinbase() == false {
+			for _, vin := range tx.Vin {
+				spent, err := spendOutput(b, vin.Txid, vin.Vout)
+				if err != nil {
+					return undo, err
+				}
+				undo.Spent = append(undo.Spent, spent)
+			}
+		}
+
+		if err := addOutputs(b, block, tx); err != nil {
+			return undo, err
+		}
+	}
+
+	return undo, checkCoinbaseValue(block, fees)
+}
+
+// spendOutput removes an output from the UTXO set and returns it for the undo
+// data
+func spendOutput(b storage.Bucket, txid []byte, vout int) (SpentOutput, error) {
+	outs, err := transaction.DeserializeOutputs(b.Get(txid))
+	if err != nil {
+		return SpentOutput{}, err
+	}
+	spent := SpentOutput{txid, vout, outs.Outputs[vout], outs.Height, outs.Coinbase}
+	delete(outs.Outputs, vout)
+
+	if len(outs.Outputs) == 0 {
+		err = b.Delete(txid)
+	} else {
+		err = b.Put(txid, outs.Serialize())
+	}
+
+	return spent, err
+}
+
+// addOutputs adds the outputs of a transaction of the block to the UTXO set
+func addOutputs(b storage.Bucket, block *Block, tx *transaction.Transaction) error {
+	outs := transaction.TXOutputs{Outputs: make(map[int]transaction.TXOutput), Height: block.Height, Coinbase: tx.IsCoinbase()}
+	for i := range tx.Vout {
+		outs.Outputs[i] = tx.Vout[i]
+	}
+
+	return b.Put(tx.ID, outs.Serialize())
+}
+
+// disconnectBlock reverts connectBlock: the outputs created by the block are
+// removed and the outputs it spent are restored from its undo data
+func disconnectBlock(b storage.Bucket, block *Block, undo BlockUndo) error {
+	next := len(undo.Spent)
+
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		tx := block.Transactions[i]
+
+		err := b.Delete(tx.ID)
+		if err != nil {
+			return err
+		}
+
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		for range tx.Vin {
+			next--
+			spent := undo.Spent[next]
+
+			outs := transaction.TXOutputs{Outputs: make(map[int]transaction.TXOutput), Height: spent.Height, Coinbase: spent.Coinbase}
+			if outsBytes := b.Get(spent.Txid); outsBytes != nil {
+				if outs, err = transaction.DeserializeOutputs(outsBytes); err != nil {
+					return err
+				}
+			}
+			outs.Outputs[spent.Vout] = spent.Output
+
+			err := b.Put(spent.Txid, outs.Serialize())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,343 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+// RejectReason identifies the consensus rule a block violates
+type RejectReason int
+
+// Reasons a block can be rejected for
+const (
+	RejectInvalidPoW RejectReason = iota
+	RejectBadHash
+	RejectBadMerkleRoot
+	RejectOrphan
+	RejectBadHeight
+	RejectBadDifficulty
+	RejectCheckpoint
+	RejectTimeTooOld
+	RejectTimeTooNew
+	RejectNoTransactions
+	RejectBadCoinbase
+	RejectBadCoinbaseValue
+	RejectBadTransaction
+	RejectDuplicateTransaction
+	RejectMissingInput
+	RejectInvalidSignature
+	RejectInsufficientInputs
+	RejectImmatureSpend
+	RejectBadVersion
+)
+
+var rejectReasons = map[RejectReason]string{
+	RejectInvalidPoW:           "proof-of-work does not meet the target",
+	RejectBadHash:              "hash does not match the block header",
+	RejectBadMerkleRoot:        "Merkle root does not match the transactions",
+	RejectOrphan:               "parent block is unknown",
+	RejectBadHeight:            "height does not follow the parent",
+	RejectBadDifficulty:        "target does not follow the retargeting rule",
+	RejectCheckpoint:           "block conflicts with a checkpoint",
+	RejectTimeTooOld:           "timestamp is not after the median time past",
+	RejectTimeTooNew:           "timestamp is too far in the future",
+	RejectNoTransactions:       "block has no transactions",
+	RejectBadCoinbase:          "first and only first transaction must be a coinbase",
+	RejectBadCoinbaseValue:     "coinbase pays more than allowed",
+	RejectBadTransaction:       "malformed transaction",
+	RejectDuplicateTransaction: "transaction is already in the chain",
+	RejectMissingInput:         "input spends a missing or already spent output",
+	RejectInvalidSignature:     "input signature is invalid",
+	RejectInsufficientInputs:   "outputs exceed inputs",
+	RejectImmatureSpend:        "input spends an immature coinbase output",
+	RejectBadVersion:           "block version is no longer accepted",
+}
+
+func (r RejectReason) String() string {
+	return rejectReasons[r]
+}
+
+// BlockError is returned when a block fails validation
+type BlockError struct {
+	Hash   []byte
+	Reason RejectReason
+	Detail string
+	// TxID identifies the transaction breaking the rule, if one does
+	TxID []byte
+}
+
+func (e *BlockError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("block %x rejected: %s", e.Hash, e.Reason)
+	}
+
+	return fmt.Sprintf("block %x rejected: %s (%s)", e.Hash, e.Reason, e.Detail)
+}
+
+func rejectBlock(block *Block, reason RejectReason, format string, a ...interface{}) *BlockError {
+	return &BlockError{Hash: block.Hash, Reason: reason, Detail: fmt.Sprintf(format, a...)}
+}
+
+// rejectTransaction is rejectBlock for a rule a transaction of the block
+// breaks
+func rejectTransaction(block *Block, tx *transaction.Transaction, reason RejectReason, format string, a ...interface{}) *BlockError {
+	err := rejectBlock(block, reason, format, a...)
+	err.TxID = tx.ID
+
+	return err
+}
+
+// checkBlock performs the validation that doesn't depend on other blocks:
+// the version, proof-of-work, the header hash, the Merkle root of the transactions, the
+// position of the coinbase and the well-formedness of every transaction
+func checkBlock(block *Block) error {
+	if len(block.Transactions) == 0 {
+		return rejectBlock(block, RejectNoTransactions, "")
+	}
+	if block.Version < blockVersion {
+		return rejectBlock(block, RejectBadVersion, "version %d", block.Version)
+	}
+
+	if hash := block.BlockHeader.Hash(); bytes.Compare(hash, block.Hash) != 0 {
+		return rejectBlock(block, RejectBadHash, "expected %x", hash)
+	}
+	if bytes.Compare(block.MerkleRoot, block.HashTransactions()) != 0 {
+		return rejectBlock(block, RejectBadMerkleRoot, "")
+	}
+	if !NewProofOfWork(&block.BlockHeader).Validate() {
+		return rejectBlock(block, RejectInvalidPoW, "")
+	}
+
+	seen := make(map[string]bool)
+	for i, tx := range block.Transactions {
+		if tx.IsCoinbase() != (i == 0) {
+			return rejectBlock(block, RejectBadCoinbase, "transaction %d", i)
+		}
+
+		if err := checkTransaction(tx); err != "" {
+			return rejectTransaction(block, tx, RejectBadTransaction, "transaction %x: %s", tx.ID, err)
+		}
+
+		txID := hex.EncodeToString(tx.ID)
+		if seen[txID] {
+			return rejectTransaction(block, tx, RejectDuplicateTransaction, "transaction %x", tx.ID)
+		}
+		seen[txID] = true
+	}
+
+	return nil
+}
+
+// pubKeyHashLen is the length of the RIPEMD-160 public key hash an output
+// pays to
+const pubKeyHashLen = 20
+
+// checkTransaction performs the context-free checks of a transaction and
+// returns a description of the first problem found
+func checkTransaction(tx *transaction.Transaction) string {
+	if len(tx.Vin) == 0 || len(tx.Vout) == 0 {
+		return "no inputs or outputs"
+	}
+
+	// IDs are computed before the inputs are signed
+	unsigned := *tx
+	unsigned.Vin = make([]transaction.TXInput, len(tx.Vin))
+	for i, vin := range tx.Vin {
+		unsigned.Vin[i] = transaction.TXInput{Txid: vin.Txid, Vout: vin.Vout, PubKey: vin.PubKey}
+	}
+	if bytes.Compare(tx.ID, unsigned.Hash()) != 0 {
+		return "ID does not match the transaction hash"
+	}
+
+	outputs := 0
+	for _, out := range tx.Vout {
+		var ok bool
+		if outputs, ok = addMoney(outputs, out.Value); !ok {
+			return "output value out of range"
+		}
+		if len(out.PubKeyHash) != pubKeyHashLen {
+			return "output does not pay to a public key hash"
+		}
+	}
+
+	if tx.IsCoinbase() {
+		return ""
+	}
+
+	for _, vin := range tx.Vin {
+		if len(vin.Txid) == 0 || vin.Vout < 0 {
+			return "null input in a regular transaction"
+		}
+	}
+
+	return ""
+}
+
+// addMoney adds an amount to a sum of amounts. It reports false when the
+// amount or the sum falls outside [0, params.MaxMoney].
+func addMoney(sum, amount int) (int, bool) {
+	if amount < 0 || amount > params.MaxMoney || sum > params.MaxMoney-amount {
+		return 0, false
+	}
+
+	return sum + amount, true
+}
+
+// checkBlockContext checks that the block agrees with the checkpoints, links
+// to a known parent, is stamped after the median time past of its parent and
+// not too far ahead of the network-adjusted time, and uses the target the
+// retargeting rule demands
+func (bc *Blockchain) checkBlockContext(block *Block) error {
+	if len(block.PrevBlockHash) == 0 {
+		return rejectBlock(block, RejectOrphan, "only the genesis block has no parent")
+	}
+
+	if err := bc.checkCheckpoints(block); err != nil {
+		return err
+	}
+
+	parent, err := bc.getParentHeader(&block.BlockHeader)
+	if err != nil {
+		return rejectBlock(block, RejectOrphan, "parent %x", block.PrevBlockHash)
+	}
+
+	if block.Height != parent.Height+1 {
+		return rejectBlock(block, RejectBadHeight, "height %d, parent height %d", block.Height, parent.Height)
+	}
+
+	if mtp := bc.medianTimePast(parent); block.Timestamp <= mtp {
+		return rejectBlock(block, RejectTimeTooOld, "timestamp %d, median time past %d", block.Timestamp, mtp)
+	}
+
+	if limit := bc.adjustedTime() + maxFutureBlockTime; block.Timestamp > limit {
+		return rejectBlock(block, RejectTimeTooNew, "timestamp %d, limit %d", block.Timestamp, limit)
+	}
+
+	bits, err := bc.nextBits(parent)
+	if err != nil {
+		return err
+	}
+	if block.Bits != bits {
+		return rejectBlock(block, RejectBadDifficulty, "bits %08x, expected %08x", block.Bits, bits)
+	}
+
+	return nil
+}
+
+// checkTransactionInputs checks a transaction against the UTXO bucket it is
+// about to be connected to: every input must spend an existing unspent
+// output with a valid signature, coinbase outputs must have matured, and the
+// inputs must cover the outputs. Signatures, and whether the inputs use the
+// keys of the outputs they spend, are only checked when verifySignatures is
+// set. The fee paid by the transaction is returned.
+func checkTransactionInputs(b storage.Bucket, block *Block, tx *transaction.Transaction, verifySignatures bool) (int, error) {
+	if b.Get(tx.ID) != nil {
+		return 0, rejectTransaction(block, tx, RejectDuplicateTransaction, "transaction %x", tx.ID)
+	}
+
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	prevTXs := make(map[string]transaction.Transaction)
+	spent := make(map[string]bool)
+	inputs := 0
+	var ok bool
+
+	for _, vin := range tx.Vin {
+		outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+		if spent[outpoint] {
+			return 0, rejectTransaction(block, tx, RejectMissingInput, "transaction %x spends %s twice", tx.ID, outpoint)
+		}
+		spent[outpoint] = true
+
+		outsBytes := b.Get(vin.Txid)
+		if outsBytes == nil {
+			return 0, rejectTransaction(block, tx, RejectMissingInput, "transaction %x input %s", tx.ID, outpoint)
+		}
+
+		outs, err := transaction.DeserializeOutputs(outsBytes)
+		if err != nil {
+			return 0, err
+		}
+		var out transaction.TXOutput
+		if out, ok = outs.Outputs[vin.Vout]; !ok {
+			return 0, rejectTransaction(block, tx, RejectMissingInput, "transaction %x input %s", tx.ID, outpoint)
+		}
+		if !outs.IsMature(block.Height) {
+			return 0, rejectTransaction(block, tx, RejectImmatureSpend, "transaction %x input %s", tx.ID, outpoint)
+		}
+		if verifySignatures && !vin.UsesKey(out.PubKeyHash) {
+			return 0, rejectTransaction(block, tx, RejectInvalidSignature, "transaction %x input %s is not signed by its owner", tx.ID, outpoint)
+		}
+		if inputs, ok = addMoney(inputs, out.Value); !ok {
+			return 0, rejectTransaction(block, tx, RejectBadTransaction, "transaction %x inputs out of range", tx.ID)
+		}
+
+		prevTXs[hex.EncodeToString(vin.Txid)] = unspentTransaction(vin.Txid, outs)
+	}
+
+	if verifySignatures {
+		if err := tx.Verify(prevTXs); err != nil {
+			return 0, rejectTransaction(block, tx, RejectInvalidSignature, "%v", err)
+		}
+	}
+
+	outputs := 0
+	for _, out := range tx.Vout {
+		if outputs, ok = addMoney(outputs, out.Value); !ok {
+			return 0, rejectTransaction(block, tx, RejectBadTransaction, "transaction %x outputs out of range", tx.ID)
+		}
+	}
+	if outputs > inputs {
+		return 0, rejectTransaction(block, tx, RejectInsufficientInputs, "transaction %x spends %d of %d", tx.ID, outputs, inputs)
+	}
+
+	return inputs - outputs, nil
+}
+
+// checkCoinbaseValue checks that the coinbase of the block claims no more
+// than the subsidy for its height plus the fees of its transactions
+func checkCoinbaseValue(block *Block, fees int) error {
+	coinbaseValue := 0
+	var ok bool
+	for _, out := range block.Transactions[0].Vout {
+		if coinbaseValue, ok = addMoney(coinbaseValue, out.Value); !ok {
+			return rejectBlock(block, RejectBadCoinbaseValue, "outputs out of range")
+		}
+	}
+
+	allowed, ok := addMoney(blockSubsidy(block.Height), fees)
+	if !ok {
+		return rejectBlock(block, RejectBadCoinbaseValue, "fees out of range")
+	}
+	if coinbaseValue > allowed {
+		return rejectBlock(block, RejectBadCoinbaseValue, "pays %d, allowed %d", coinbaseValue, allowed)
+	}
+
+	return nil
+}
+
+// unspentTransaction rebuilds the part of a transaction that is still in the
+// UTXO set, with every output at its original index, so it can be passed to
+// Transaction.Verify
+func unspentTransaction(ID []byte, outs transaction.TXOutputs) transaction.Transaction {
+	size := 0
+	for i := range outs.Outputs {
+		if i+1 > size {
+			size = i + 1
+		}
+	}
+
+	tx := transaction.Transaction{ID: ID, Vout: make([]transaction.TXOutput, size)}
+	for i, out := range outs.Outputs {
+		tx.Vout[i] = out
+	}
+
+	return tx
+}
@@ -0,0 +1,260 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+// Levels of VerifyChain. Each level performs the checks of the levels below
+// it as well.
+const (
+	// VerifyHeaders checks the hash, proof-of-work, parent link, height,
+	// target, timestamp and checkpoint of every main chain block
+	VerifyHeaders = iota + 1
+	// VerifyMerkle checks the Merkle root and the well-formedness of the
+	// transactions of every block after the genesis block that still has
+	// them
+	VerifyMerkle
+	// VerifySignatures replays the main chain from the genesis block,
+	// checking the inputs, signatures and coinbase value of every block
+	VerifySignatures
+	// VerifyUTXO compares the UTXO set built by the replay with the
+	// chainstate bucket
+	VerifyUTXO
+)
+
+// ErrUTXOMismatch is returned when the chainstate bucket doesn't hold the
+// outputs the main chain leaves unspent
+var ErrUTXOMismatch = errors.New("the chainstate does not match the blocks")
+
+// VerifyError reports the main chain block VerifyChain failed at
+type VerifyError struct {
+	Height int
+	Hash   []byte
+	Err    error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("block %d (%x): %v", e.Height, e.Hash, e.Err)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyChain audits the main chain in the DB up to the given level and
+// returns the number of blocks checked. The first block failing a check is
+// reported with a *VerifyError, whose Err is a *BlockError for consensus
+// rule violations. The blocks converted from gob are held to the rules of
+// the old nodes, which checked the proof-of-work and the inputs only. Levels from VerifySignatures up need every block, so
+// ErrPruned is returned on pruned nodes.
+func (bc *Blockchain) VerifyChain(level int) (int, error) {
+	if level < VerifyHeaders || level > VerifyUTXO {
+		return 0, fmt.Errorf("unknown verification level %d", level)
+	}
+
+	// The chain must not change under the audit
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	tip, err := bc.tipHeader()
+	if err != nil {
+		return 0, err
+	}
+
+	var replay storage.Storage
+	if level >= VerifySignatures {
+		pruned, err := bc.IsPruned()
+		if err != nil {
+			return 0, err
+		}
+		if pruned {
+			return 0, ErrPruned
+		}
+
+		replay = storage.NewMemory()
+		defer replay.Close()
+		err = replay.Update(func(tx storage.Tx) error {
+			_, err := tx.CreateBucket([]byte(utxoBucket))
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var parent *BlockHeader
+	for height := 0; height <= tip.Height; height++ {
+		hash, err := bc.GetBlockHash(height)
+		if err != nil {
+			return height, &VerifyError{height, nil, err}
+		}
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			return height, &VerifyError{height, hash, err}
+		}
+
+		var migrated bool
+		err = bc.db.View(func(tx storage.Tx) error {
+			migrated = isMigrated(tx, hash)
+			return nil
+		})
+		if err != nil {
+			return height, err
+		}
+
+		if err := bc.verifyHeader(&block, hash, parent, migrated); err != nil {
+			return height, &VerifyError{height, hash, err}
+		}
+
+		// The genesis block matches the network parameters, see
+		// newGenesisCoinbase
+		if level >= VerifyMerkle && parent != nil && !migrated && !block.IsPruned() {
+			if err := checkBlock(&block); err != nil {
+				return height, &VerifyError{height, hash, err}
+			}
+		}
+
+		if replay != nil {
+			err := replay.Update(func(tx storage.Tx) error {
+				b := tx.Bucket([]byte(utxoBucket))
+				var err error
+				switch {
+				case parent == nil:
+					err = connectGenesis(b, &block)
+				case migrated:
+					_, err = connectLegacyBlock(b, &block)
+				default:
+					_, err = connectBlock(b, &block, true)
+				}
+				return err
+			})
+			if err != nil {
+				return height, &VerifyError{height, hash, err}
+			}
+		}
+
+		parent = &block.BlockHeader
+	}
+
+	if tipHash := bc.Tip(); !bytes.Equal(parent.Hash(), tipHash) {
+		return tip.Height + 1, &VerifyError{tip.Height, parent.Hash(), fmt.Errorf("the main chain tip is %x", tipHash)}
+	}
+
+	if level >= VerifyUTXO {
+		if err := bc.compareUTXO(replay); err != nil {
+			return tip.Height + 1, err
+		}
+	}
+
+	return tip.Height + 1, nil
+}
+
+// verifyHeader checks the header of the main chain block stored under hash
+// against the header of its parent, which is nil for the genesis block.
+// The timestamp isn't held against the current time: that rule only applies
+// when a block is received. The old nodes enforced no version, checkpoint,
+// median time past or retargeting, so blocks converted from gob are spared
+// those rules.
+func (bc *Blockchain) verifyHeader(block *Block, hash []byte, parent *BlockHeader, migrated bool) error {
+	if !migrated && block.Version < blockVersion {
+		return rejectBlock(block, RejectBadVersion, "version %d", block.Version)
+	}
+	if !bytes.Equal(hash, block.BlockHeader.Hash()) {
+		return rejectBlock(block, RejectBadHash, "stored as %x", hash)
+	}
+	if !NewProofOfWork(&block.BlockHeader).Validate() {
+		return rejectBlock(block, RejectInvalidPoW, "")
+	}
+	if cp := checkpointHash(block.Height); cp != nil && !migrated && !bytes.Equal(cp, hash) {
+		return rejectBlock(block, RejectCheckpoint, "expected %x at height %d", cp, block.Height)
+	}
+
+	if parent == nil {
+		if len(block.PrevBlockHash) != 0 || block.Height != 0 {
+			return rejectBlock(block, RejectBadHeight, "the first block of the main chain isn't a genesis block")
+		}
+
+		return nil
+	}
+
+	if !bytes.Equal(block.PrevBlockHash, parent.Hash()) {
+		return rejectBlock(block, RejectOrphan, "parent %x, the main chain has %x", block.PrevBlockHash, parent.Hash())
+	}
+	if block.Height != parent.Height+1 {
+		return rejectBlock(block, RejectBadHeight, "height %d, parent height %d", block.Height, parent.Height)
+	}
+	if migrated {
+		return nil
+	}
+
+	if mtp := bc.medianTimePast(parent); block.Timestamp <= mtp {
+		return rejectBlock(block, RejectTimeTooOld, "timestamp %d, median time past %d", block.Timestamp, mtp)
+	}
+
+	bits, err := bc.nextBits(parent)
+	if err != nil {
+		return err
+	}
+	if block.Bits != bits {
+		return rejectBlock(block, RejectBadDifficulty, "bits %08x, expected %08x", block.Bits, bits)
+	}
+
+	return nil
+}
+
+// compareUTXO compares the chainstate bucket with the UTXO set replayed from
+// the blocks. Both are walked in key order and the first transaction they
+// disagree on is reported, with the block that created it when that can be
+// told.
+func (bc *Blockchain) compareUTXO(replay storage.Storage) error {
+	var txID, outsBytes []byte
+	var problem string
+
+	err := bc.db.View(func(tx storage.Tx) error {
+		return replay.View(func(rtx storage.Tx) error {
+			sc := tx.Bucket([]byte(utxoBucket)).Cursor()
+			rc := rtx.Bucket([]byte(utxoBucket)).Cursor()
+			sk, sv := sc.First()
+			rk, rv := rc.First()
+
+			for sk != nil || rk != nil {
+				switch {
+				case sk == nil || rk != nil && bytes.Compare(rk, sk) < 0:
+					txID, outsBytes, problem = rk, rv, "is missing"
+				case rk == nil || bytes.Compare(sk, rk) < 0:
+					txID, outsBytes, problem = sk, sv, "should not be there"
+				case !bytes.Equal(sv, rv):
+					txID, outsBytes, problem = sk, rv, "has other outputs"
+				default:
+					sk, sv = sc.Next()
+					rk, rv = rc.Next()
+					continue
+				}
+
+				txID = append([]byte{}, txID...)
+				outsBytes = append([]byte{}, outsBytes...)
+				return nil
+			}
+
+			return nil
+		})
+	})
+	if err != nil || txID == nil {
+		return err
+	}
+
+	err = fmt.Errorf("%w: transaction %x %s", ErrUTXOMismatch, txID, problem)
+
+	outs, decodeErr := transaction.DeserializeOutputs(outsBytes)
+	if decodeErr != nil {
+		return err
+	}
+	hash, _ := bc.GetBlockHash(outs.Height)
+
+	return &VerifyError{outs.Height, hash, err}
+}
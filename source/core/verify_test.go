@@ -0,0 +1,83 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+func TestVerifyChain(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	address := string(w.GetAddress())
+	to := string(newWallet(t).GetAddress())
+
+	tx := newTransaction(t, bc, w, to, 3, 1)
+	block := mineBlock(t, bc, address, []*transaction.Transaction{tx})
+	mineBlock(t, bc, address, nil)
+
+	for level := VerifyHeaders; level <= VerifyUTXO; level++ {
+		count, err := bc.VerifyChain(level)
+		assert.NoError(t, err, "level %d", level)
+		assert.Equal(t, 3, count)
+	}
+	_, err := bc.VerifyChain(VerifyUTXO + 1)
+	assert.Error(t, err)
+
+	// A lost output is only noticed by the UTXO comparison
+	bc.db.Update(func(dbTx storage.Tx) error {
+		return dbTx.Bucket([]byte(utxoBucket)).Delete(tx.ID)
+	})
+	_, err = bc.VerifyChain(VerifySignatures)
+	assert.NoError(t, err)
+	_, err = bc.VerifyChain(VerifyUTXO)
+	assert.True(t, errors.Is(err, ErrUTXOMismatch))
+	var verifyErr *VerifyError
+	if assert.True(t, errors.As(err, &verifyErr)) {
+		assert.Equal(t, 1, verifyErr.Height)
+		assert.Equal(t, block.Hash, verifyErr.Hash)
+	}
+	assert.NoError(t, UTXOSet{bc}.Reindex())
+
+	// Transactions changed under an intact header break the Merkle root
+	tampered := *block
+	tampered.Transactions = []*transaction.Transaction{block.Transactions[0]}
+	bc.db.Update(func(dbTx storage.Tx) error {
+		return dbTx.Bucket([]byte(blocksBucket)).Put(block.Hash, tampered.Serialize())
+	})
+	_, err = bc.VerifyChain(VerifyHeaders)
+	assert.NoError(t, err)
+	_, err = bc.VerifyChain(VerifyMerkle)
+	var blockErr *BlockError
+	if assert.True(t, errors.As(err, &blockErr)) {
+		assert.Equal(t, RejectBadMerkleRoot, blockErr.Reason)
+	}
+
+	// So does a header that no longer hashes to its key
+	tampered.Nonce++
+	bc.db.Update(func(dbTx storage.Tx) error {
+		return dbTx.Bucket([]byte(blocksBucket)).Put(block.Hash, tampered.Serialize())
+	})
+	count, err := bc.VerifyChain(VerifyHeaders)
+	assert.Equal(t, 1, count)
+	if assert.True(t, errors.As(err, &blockErr)) {
+		assert.Equal(t, RejectBadHash, blockErr.Reason)
+	}
+}
+
+func TestVerifyChainPruned(t *testing.T) {
+	bc, w := newTestBlockchain(t)
+	for i := 0; i < 3; i++ {
+		mineBlock(t, bc, string(w.GetAddress()), nil)
+	}
+	_, err := bc.Prune(1)
+	assert.NoError(t, err)
+
+	_, err = bc.VerifyChain(VerifyMerkle)
+	assert.NoError(t, err, "pruned blocks are checked up to their headers")
+	_, err = bc.VerifyChain(VerifySignatures)
+	assert.Equal(t, ErrPruned, err)
+}
@@ -1,6 +1,8 @@
 package main
 
+import "github.com/aQuaYi/Blockchain-in-Go/source/cli"
+
 func main() {
-	cli := CLI{}
-	cli.Run()
+	c := cli.CLI{}
+	c.Run()
 }
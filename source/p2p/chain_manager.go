@@ -0,0 +1,260 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+// chainManager owns the blockchain and the mempool of a node. Blocks are
+// connected, mined and validated, and the mempool is changed, under one
+// lock, so the mempool always matches the chain it was checked against.
+// Reads go to the blockchain directly, it is safe for concurrent use.
+// Mempool changes are published on the notifier of the blockchain, after
+// the events of the chain change that caused them.
+type chainManager struct {
+	mu      sync.Mutex
+	bc      *core.Blockchain
+	mempool map[string]transaction.Transaction
+	// spent maps the outputs spent by mempool transactions, as outpoints, to
+	// the ID of the transaction spending them
+	spent map[string]string
+}
+
+// errConflict is returned when a transaction spends an output a mempool
+// transaction already spends
+var errConflict = errors.New("transaction conflicts with a mempool transaction")
+
+func newChainManager(bc *core.Blockchain) *chainManager {
+	return &chainManager{
+		bc:      bc,
+		mempool: make(map[string]transaction.Transaction),
+		spent:   make(map[string]string),
+	}
+}
+
+// outpoint identifies the output an input spends
+func outpoint(vin transaction.TXInput) string {
+	return fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+}
+
+// addBlock adds a block to the chain. The transactions of the connected
+// blocks leave the mempool, along with the mempool transactions conflicting
+// with them. After a reorganization the mempool is checked against the new
+// tip, and the transactions of the disconnected blocks that are still valid
+// return to it.
+func (m *chainManager) addBlock(block *core.Block) (disconnected, connected []*core.Block, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	disconnected, connected, err = m.bc.AddBlock(block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var events []core.Event
+	for _, block := range connected {
+		events = append(events, m.removeMined(block, block.Transactions)...)
+		events = append(events, m.removeConflicts(block)...)
+	}
+
+	if len(disconnected) > 0 {
+		events = append(events, m.removeInvalid()...)
+
+		for _, block := range disconnected {
+			for _, tx := range block.Transactions {
+				if !tx.IsCoinbase() && m.check(tx) == nil {
+					m.add(*tx)
+					events = append(events, core.Event{Type: core.TxAccepted, Tx: tx})
+				}
+			}
+		}
+	}
+	m.bc.Notifier().Publish(events...)
+
+	return disconnected, connected, nil
+}
+
+// validateSnapshotBlock validates a historical block of a loaded UTXO
+// snapshot, see Blockchain.ValidateSnapshotBlock
+func (m *chainManager) validateSnapshotBlock(block *core.Block) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.bc.ValidateSnapshotBlock(block)
+}
+
+// addTransaction verifies a transaction against the tip and adds it to the
+// mempool. errConflict is returned when it spends an output a mempool
+// transaction spends already.
+func (m *chainManager) addTransaction(tx transaction.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.mempool[hex.EncodeToString(tx.ID)]; ok {
+		return nil
+	}
+
+	if err := m.check(&tx); err != nil {
+		return err
+	}
+
+	m.add(tx)
+	m.bc.Notifier().Publish(core.Event{Type: core.TxAccepted, Tx: &tx})
+
+	return nil
+}
+
+// check verifies a transaction against the tip and the mempool. The caller
+// holds mu.
+func (m *chainManager) check(tx *transaction.Transaction) error {
+	if err := m.bc.VerifyTransaction(tx); err != nil {
+		return err
+	}
+	if _, err := (core.UTXOSet{Blockchain: m.bc}).Fee(tx); err != nil {
+		return err
+	}
+	for _, vin := range tx.Vin {
+		if other, ok := m.spent[outpoint(vin)]; ok {
+			return fmt.Errorf("%w: both spend %s, %s does", errConflict, outpoint(vin), other)
+		}
+	}
+
+	return nil
+}
+
+// add puts a transaction in the mempool. The caller holds mu and has checked
+// the transaction.
+func (m *chainManager) add(tx transaction.Transaction) {
+	id := hex.EncodeToString(tx.ID)
+	m.mempool[id] = tx
+	for _, vin := range tx.Vin {
+		m.spent[outpoint(vin)] = id
+	}
+}
+
+// remove takes the transaction with the ID out of the mempool, if it is
+// there. The caller holds mu.
+func (m *chainManager) remove(id string) (transaction.Transaction, bool) {
+	tx, ok := m.mempool[id]
+	if !ok {
+		return tx, false
+	}
+
+	delete(m.mempool, id)
+	for _, vin := range tx.Vin {
+		if m.spent[outpoint(vin)] == id {
+			delete(m.spent, outpoint(vin))
+		}
+	}
+
+	return tx, true
+}
+
+// transaction returns the mempool transaction with the ID
+func (m *chainManager) transaction(ID []byte) (transaction.Transaction, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, ok := m.mempool[hex.EncodeToString(ID)]
+
+	return tx, ok
+}
+
+// mempoolSize returns the number of transactions in the mempool
+func (m *chainManager) mempoolSize() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.mempool)
+}
+
+// mine mines a block with the mempool transactions that are still valid and
+// removes them from the mempool. A transaction that makes the block invalid
+// is dropped from the mempool and the block is mined without it. No block is
+// mined, and nil is returned, when no transaction is valid.
+func (m *chainManager) mine(minerAddress string) (*core.Block, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		var txs []*transaction.Transaction
+		for id := range m.mempool {
+			tx := m.mempool[id]
+			if m.bc.VerifyTransaction(&tx) == nil {
+				txs = append(txs, &tx)
+			}
+		}
+
+		if len(txs) == 0 {
+			return nil, nil
+		}
+
+		newBlock, err := m.bc.MineBlock(minerAddress, txs)
+		var blockErr *core.BlockError
+		if errors.As(err, &blockErr) && blockErr.TxID != nil {
+			if tx, ok := m.remove(hex.EncodeToString(blockErr.TxID)); ok {
+				m.bc.Notifier().Publish(core.Event{Type: core.TxRemoved, Tx: &tx})
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m.bc.Notifier().Publish(m.removeMined(newBlock, txs)...)
+
+		return newBlock, nil
+	}
+}
+
+// removeConflicts removes the mempool transactions spending outputs the
+// transactions of the block spend, and returns the events reporting them
+func (m *chainManager) removeConflicts(block *core.Block) []core.Event {
+	var events []core.Event
+	for _, tx := range block.Transactions {
+		for _, vin := range tx.Vin {
+			id, ok := m.spent[outpoint(vin)]
+			if !ok {
+				continue
+			}
+			if conflict, ok := m.remove(id); ok {
+				events = append(events, core.Event{Type: core.TxRemoved, Tx: &conflict})
+			}
+		}
+	}
+
+	return events
+}
+
+// removeInvalid removes the mempool transactions that are no longer valid at
+// the tip, and returns the events reporting them
+func (m *chainManager) removeInvalid() []core.Event {
+	var events []core.Event
+	for id := range m.mempool {
+		tx := m.mempool[id]
+		if m.bc.VerifyTransaction(&tx) != nil {
+			m.remove(id)
+			events = append(events, core.Event{Type: core.TxRemoved, Tx: &tx})
+		}
+	}
+
+	return events
+}
+
+// removeMined removes the transactions the block includes from the mempool
+// and returns the events reporting the ones it held
+func (m *chainManager) removeMined(block *core.Block, txs []*transaction.Transaction) []core.Event {
+	var events []core.Event
+	for _, tx := range txs {
+		if _, ok := m.remove(hex.EncodeToString(tx.ID)); ok {
+			events = append(events, core.Event{Type: core.TxRemoved, Hash: block.Hash, Height: block.Height, Tx: tx})
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,199 @@
+package p2p
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/storage"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+// newTestChainManager creates a chain manager keeping a regtest blockchain
+// in memory and returns it together with the wallet the genesis block
+// premines to. Coinbase outputs are spendable at once.
+func newTestChainManager(t *testing.T) (*chainManager, *wallet.Wallet) {
+	saved := params.Active
+	testParams := params.RegTest
+	testParams.CoinbaseMaturity = 0
+	params.Active = &testParams
+
+	w := newWallet(t)
+	premine := []byte(fmt.Sprintf(`[{"Address": "%s", "Amount": %d}]`, w.GetAddress(), params.Active.InitialSubsidy))
+	testParams.PremineFile = filepath.Join(t.TempDir(), "premine.json")
+	testParams.GenesisHash = ""
+	testParams.Checkpoints = nil
+	if err := ioutil.WriteFile(testParams.PremineFile, premine, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bc, err := core.OpenBlockchain(storage.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		bc.Close()
+		params.Active = saved
+	})
+
+	return newChainManager(bc), w
+}
+
+func newWallet(t *testing.T) *wallet.Wallet {
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func newTransaction(t *testing.T, bc *core.Blockchain, w *wallet.Wallet, to string, amount, fee int) *transaction.Transaction {
+	tx, err := core.NewUTXOTransaction(w, to, amount, fee, &core.UTXOSet{Blockchain: bc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func TestMempoolConflicts(t *testing.T) {
+	m, w := newTestChainManager(t)
+	miner := string(newWallet(t).GetAddress())
+	s := m.bc.Notifier().Subscribe(10)
+
+	// Both spend the genesis coinbase
+	first := newTransaction(t, m.bc, w, string(newWallet(t).GetAddress()), 3, 1)
+	second := newTransaction(t, m.bc, w, string(newWallet(t).GetAddress()), 4, 1)
+
+	assert.NoError(t, m.addTransaction(*first))
+	assert.ErrorIs(t, m.addTransaction(*second), errConflict)
+	assert.Equal(t, 1, m.mempoolSize())
+	assert.NoError(t, m.addTransaction(*first), "known transactions are ignored")
+
+	// A conflict that got in anyway is dropped when mining
+	m.add(*second)
+	block, err := m.mine(miner)
+	if !assert.NoError(t, err) || !assert.NotNil(t, block) {
+		return
+	}
+	assert.Len(t, block.Transactions, 2)
+	assert.Equal(t, 0, m.mempoolSize())
+	assert.Empty(t, m.spent)
+
+	var removed int
+	for _, event := range receive(s) {
+		if event.Type == core.TxRemoved {
+			removed++
+		}
+	}
+	assert.Equal(t, 2, removed)
+
+	block, err = m.mine(miner)
+	assert.NoError(t, err)
+	assert.Nil(t, block)
+}
+
+// newCompetingChain creates a blockchain sharing the genesis block of m and
+// returns it with a function mining a block on it and passing the block to m.
+// The function returns the blocks m disconnects.
+func newCompetingChain(t *testing.T, m *chainManager) (*core.Blockchain, func(txs ...*transaction.Transaction) []*core.Block) {
+	miner := string(newWallet(t).GetAddress())
+	other, err := core.OpenBlockchain(storage.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { other.Close() })
+
+	return other, func(txs ...*transaction.Transaction) []*core.Block {
+		block, err := other.MineBlock(miner, txs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		disconnected, _, err := m.addBlock(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return disconnected
+	}
+}
+
+func TestMempoolFollowsReorganizations(t *testing.T) {
+	m, w := newTestChainManager(t)
+	miner := string(newWallet(t).GetAddress())
+	other, mine := newCompetingChain(t, m)
+
+	first := newTransaction(t, m.bc, w, string(newWallet(t).GetAddress()), 3, 1)
+	second := newTransaction(t, other, w, string(newWallet(t).GetAddress()), 4, 1)
+	assert.NoError(t, m.addTransaction(*first))
+	if _, err := m.mine(miner); err != nil {
+		t.Fatal(err)
+	}
+	s := m.bc.Notifier().Subscribe(10)
+
+	// An empty branch taking over returns the transaction to the mempool
+	disconnected := append(mine(), mine()...)
+	assert.Len(t, disconnected, 1)
+	_, ok := m.transaction(first.ID)
+	assert.True(t, ok)
+
+	// A block spending the same output evicts it
+	mine(second)
+	assert.Equal(t, 0, m.mempoolSize())
+	assert.Empty(t, m.spent)
+
+	var accepted, removed []core.Event
+	for _, event := range receive(s) {
+		switch event.Type {
+		case core.TxAccepted:
+			accepted = append(accepted, event)
+		case core.TxRemoved:
+			removed = append(removed, event)
+		}
+	}
+	if assert.Len(t, accepted, 1) && assert.Len(t, removed, 1) {
+		assert.Equal(t, first.ID, accepted[0].Tx.ID)
+		assert.Equal(t, first.ID, removed[0].Tx.ID)
+		assert.Empty(t, removed[0].Hash, "the transaction wasn't mined")
+	}
+}
+
+func TestMempoolDropsTransactionsTheNewBranchSpends(t *testing.T) {
+	m, w := newTestChainManager(t)
+	miner := string(newWallet(t).GetAddress())
+	other, mine := newCompetingChain(t, m)
+
+	first := newTransaction(t, m.bc, w, string(newWallet(t).GetAddress()), 3, 1)
+	second := newTransaction(t, other, w, string(newWallet(t).GetAddress()), 4, 1)
+	assert.NoError(t, m.addTransaction(*first))
+	if _, err := m.mine(miner); err != nil {
+		t.Fatal(err)
+	}
+
+	// On equal work the first block may take over at once
+	disconnected := append(mine(second), mine()...)
+	assert.Len(t, disconnected, 1)
+	assert.Equal(t, 0, m.mempoolSize(), "the new branch spends the inputs of the disconnected transaction")
+	assert.Empty(t, m.spent)
+}
+
+// receive returns the events waiting on the subscription
+func receive(s *core.Subscription) []core.Event {
+	var events []core.Event
+	for {
+		select {
+		case event := <-s.Events():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+// A request is the command, padded with zero bytes to commandLength,
+// followed by the payload of the command in the canonical encoding described
+// in package utils. Strings are written as bytes and lists as a uvarint
+// count followed by the elements. The payloads hold these fields, in order:
+//
+//	addr       AddrList list of strings
+//	block      AddrFrom string, Block bytes
+//	getblocks  AddrFrom string
+//	getdata    AddrFrom string, Type string, ID bytes
+//	notfound   AddrFrom string, Type string, ID bytes
+//	inv        AddrFrom string, Type string, Items list of bytes
+//	tx         AddrFrom string, Transaction bytes
+//	version    Version varint, BestHeight varint, AddrFrom string,
+//	           Pruned bool, Timestamp int64
+
+// message is the payload of a request
+type message interface {
+	encode(w *utils.Writer)
+	decode(r *utils.Reader)
+}
+
+type addr struct {
+	AddrList []string
+}
+
+func (m *addr) encode(w *utils.Writer) {
+	w.Uvarint(uint64(len(m.AddrList)))
+	for _, address := range m.AddrList {
+		w.VarBytes([]byte(address))
+	}
+}
+
+func (m *addr) decode(r *utils.Reader) {
+	for n := r.Count(); n > 0; n-- {
+		m.AddrList = append(m.AddrList, string(r.VarBytes()))
+	}
+}
+
+type block struct {
+	AddrFrom string
+	Block    []byte
+}
+
+func (m *block) encode(w *utils.Writer) {
+	w.VarBytes([]byte(m.AddrFrom))
+	w.VarBytes(m.Block)
+}
+
+func (m *block) decode(r *utils.Reader) {
+	m.AddrFrom = string(r.VarBytes())
+	m.Block = r.VarBytes()
+}
+
+type getblocks struct {
+	AddrFrom string
+}
+
+func (m *getblocks) encode(w *utils.Writer) {
+	w.VarBytes([]byte(m.AddrFrom))
+}
+
+func (m *getblocks) decode(r *utils.Reader) {
+	m.AddrFrom = string(r.VarBytes())
+}
+
+type getdata struct {
+	AddrFrom string
+	Type     string
+	ID       []byte
+}
+
+func (m *getdata) encode(w *utils.Writer) {
+	w.VarBytes([]byte(m.AddrFrom))
+	w.VarBytes([]byte(m.Type))
+	w.VarBytes(m.ID)
+}
+
+func (m *getdata) decode(r *utils.Reader) {
+	m.AddrFrom = string(r.VarBytes())
+	m.Type = string(r.VarBytes())
+	m.ID = r.VarBytes()
+}
+
+type notfound struct {
+	AddrFrom string
+	Type     string
+	ID       []byte
+}
+
+func (m *notfound) encode(w *utils.Writer) {
+	w.VarBytes([]byte(m.AddrFrom))
+	w.VarBytes([]byte(m.Type))
+	w.VarBytes(m.ID)
+}
+
+func (m *notfound) decode(r *utils.Reader) {
+	m.AddrFrom = string(r.VarBytes())
+	m.Type = string(r.VarBytes())
+	m.ID = r.VarBytes()
+}
+
+type inv struct {
+	AddrFrom string
+	Type     string
+	Items    [][]byte
+}
+
+func (m *inv) encode(w *utils.Writer) {
+	w.VarBytes([]byte(m.AddrFrom))
+	w.VarBytes([]byte(m.Type))
+	w.Uvarint(uint64(len(m.Items)))
+	for _, item := range m.Items {
+		w.VarBytes(item)
+	}
+}
+
+func (m *inv) decode(r *utils.Reader) {
+	m.AddrFrom = string(r.VarBytes())
+	m.Type = string(r.VarBytes())
+	for n := r.Count(); n > 0; n-- {
+		m.Items = append(m.Items, r.VarBytes())
+	}
+}
+
+type tx struct {
+	AddFrom     string
+	Transaction []byte
+}
+
+func (m *tx) encode(w *utils.Writer) {
+	w.VarBytes([]byte(m.AddFrom))
+	w.VarBytes(m.Transaction)
+}
+
+func (m *tx) decode(r *utils.Reader) {
+	m.AddFrom = string(r.VarBytes())
+	m.Transaction = r.VarBytes()
+}
+
+type verzion struct {
+	Version    int
+	BestHeight int
+	AddrFrom   string
+	Pruned     bool
+	Timestamp  int64
+}
+
+func (m *verzion) encode(w *utils.Writer) {
+	w.Varint(int64(m.Version))
+	w.Varint(int64(m.BestHeight))
+	w.VarBytes([]byte(m.AddrFrom))
+	w.Bool(m.Pruned)
+	w.Int64(m.Timestamp)
+}
+
+func (m *verzion) decode(r *utils.Reader) {
+	m.Version = r.Int()
+	m.BestHeight = r.Int()
+	m.AddrFrom = string(r.VarBytes())
+	m.Pruned = r.Bool()
+	m.Timestamp = r.Int64()
+}
+
+// newRequest builds the request of a command
+func newRequest(command string, m message) []byte {
+	var w utils.Writer
+	m.encode(&w)
+
+	return append(commandToBytes(command), w.Data()...)
+}
+
+// decodeRequest decodes the payload of a request into m
+func decodeRequest(request []byte, m message) error {
+	r := utils.NewReader(request[commandLength:])
+	m.decode(r)
+	if err := r.Finish(); err != nil {
+		return fmt.Errorf("%s payload: %w", bytesToCommand(request[:commandLength]), err)
+	}
+
+	return nil
+}
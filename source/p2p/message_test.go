@@ -0,0 +1,33 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+func TestMessages(t *testing.T) {
+	messages := map[string]struct{ sent, received message }{
+		"addr":      {&addr{[]string{"localhost:3000", "localhost:3001"}}, &addr{}},
+		"block":     {&block{"localhost:3000", []byte("block")}, &block{}},
+		"getblocks": {&getblocks{"localhost:3000"}, &getblocks{}},
+		"getdata":   {&getdata{"localhost:3000", "tx", []byte("id")}, &getdata{}},
+		"notfound":  {&notfound{"localhost:3000", "block", []byte("id")}, &notfound{}},
+		"inv":       {&inv{"localhost:3000", "block", [][]byte{[]byte("a"), []byte("b")}}, &inv{}},
+		"tx":        {&tx{"localhost:3000", []byte("tx")}, &tx{}},
+		"version":   {&verzion{3, 7, "localhost:3000", true, -1}, &verzion{}},
+	}
+
+	for command, m := range messages {
+		request := newRequest(command, m.sent)
+		assert.Equal(t, command, bytesToCommand(request[:commandLength]))
+		if assert.NoError(t, decodeRequest(request, m.received), command) {
+			assert.Equal(t, m.sent, m.received, command)
+		}
+
+		err := decodeRequest(append(request, 0), m.received)
+		assert.ErrorIs(t, err, utils.ErrMalformed, "%s with a trailing byte", command)
+	}
+}
@@ -0,0 +1,704 @@
+// Package p2p implements the protocol nodes use to exchange blocks and
+// transactions.
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/core"
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/transaction"
+)
+
+const protocol = "tcp"
+const commandLength = 12
+
+// Server is a node of the network. Each connection is handled on its own
+// goroutine: the chain and the mempool are changed through the chain
+// manager, and the rest of the state of the node is guarded by mu.
+type Server struct {
+	nodeAddress   string
+	miningAddress string
+	pruneDepth    int
+	chain         *chainManager
+
+	mu              sync.Mutex
+	knownNodes      []string
+	blocksInTransit [][]byte
+
+	// listener accepts the connections of the node; closing it after
+	// setting stopErr makes Run return stopErr
+	listener net.Listener
+	stopErr  error
+
+	// handlers tracks the goroutines handling connections, which use the
+	// chain until they return
+	handlers sync.WaitGroup
+}
+
+// NewServer returns a node listening at nodeAddress and keeping bc. Mining
+// is on when minerAddress is set, and a positive prune depth keeps only the
+// transactions of that many recent blocks.
+func NewServer(nodeAddress string, bc *core.Blockchain, minerAddress string, pruneDepth int) *Server {
+	return &Server{
+		nodeAddress:   nodeAddress,
+		miningAddress: minerAddress,
+		pruneDepth:    pruneDepth,
+		chain:         newChainManager(bc),
+		knownNodes:    append([]string{}, params.Active.KnownNodes...),
+	}
+}
+
+func commandToBytes(command string) []byte {
+	var bytes [commandLength]byte
+
+	for i, c := range command {
+		bytes[i] = byte(c)
+	}
+
+	return bytes[:]
+}
+
+func bytesToCommand(bytes []byte) string {
+	var command []byte
+
+	for _, b := range bytes {
+		if b != 0x0 {
+			command = append(command, b)
+		}
+	}
+
+	return fmt.Sprintf("%s", command)
+}
+
+func extractCommand(request []byte) []byte {
+	return request[:commandLength]
+}
+
+// peers returns a copy of the known nodes
+func (s *Server) peers() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.knownNodes...)
+}
+
+// addPeers adds the nodes that aren't known yet and returns the number of
+// known nodes
+func (s *Server) addPeers(addrs ...string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+Addrs:
+	for _, addr := range addrs {
+		for _, node := range s.knownNodes {
+			if node == addr {
+				continue Addrs
+			}
+		}
+		s.knownNodes = append(s.knownNodes, addr)
+	}
+
+	return len(s.knownNodes)
+}
+
+// removePeer forgets a node
+func (s *Server) removePeer(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var updatedNodes []string
+	for _, node := range s.knownNodes {
+		if node != addr {
+			updatedNodes = append(updatedNodes, node)
+		}
+	}
+
+	s.knownNodes = updatedNodes
+}
+
+// isCentral tells whether the node is the first known node, which relays
+// transactions to the others
+func (s *Server) isCentral() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.knownNodes) > 0 && s.knownNodes[0] == s.nodeAddress
+}
+
+// setBlocksInTransit replaces the blocks to download, in download order
+func (s *Server) setBlocksInTransit(hashes [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blocksInTransit = hashes
+}
+
+// nextBlockInTransit takes the next block to download off the list, or
+// returns nil when there is none
+func (s *Server) nextBlockInTransit() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.blocksInTransit) == 0 {
+		return nil
+	}
+
+	blockHash := s.blocksInTransit[0]
+	s.blocksInTransit = s.blocksInTransit[1:]
+
+	return blockHash
+}
+
+func (s *Server) requestBlocks() {
+	for _, node := range s.peers() {
+		s.sendGetBlocks(node)
+	}
+}
+
+func (s *Server) sendAddr(address string) {
+	nodes := addr{s.peers()}
+	nodes.AddrList = append(nodes.AddrList, s.nodeAddress)
+	request := newRequest("addr", &nodes)
+
+	s.sendData(address, request)
+}
+
+func (s *Server) sendBlock(addr string, b *core.Block) {
+	data := block{s.nodeAddress, b.Serialize()}
+	request := newRequest("block", &data)
+
+	s.sendData(addr, request)
+}
+
+// sendData sends a request to the node at addr, forgetting the node when it
+// can't be reached
+func (s *Server) sendData(addr string, data []byte) {
+	err := sendData(addr, data)
+	var dialErr *net.OpError
+	if errors.As(err, &dialErr) && dialErr.Op == "dial" {
+		fmt.Printf("%s is not available\n", addr)
+		s.removePeer(addr)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Can't send to %s: %v\n", addr, err)
+	}
+}
+
+func sendData(addr string, data []byte) error {
+	conn, err := net.Dial(protocol, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = io.Copy(conn, bytes.NewReader(data))
+
+	return err
+}
+
+func (s *Server) sendInv(address, kind string, items [][]byte) {
+	inventory := inv{s.nodeAddress, kind, items}
+	request := newRequest("inv", &inventory)
+
+	s.sendData(address, request)
+}
+
+func (s *Server) sendGetBlocks(address string) {
+	request := newRequest("getblocks", &getblocks{s.nodeAddress})
+
+	s.sendData(address, request)
+}
+
+func (s *Server) sendGetData(address, kind string, id []byte) {
+	request := newRequest("getdata", &getdata{s.nodeAddress, kind, id})
+
+	s.sendData(address, request)
+}
+
+func (s *Server) sendNotFound(address, kind string, id []byte) {
+	request := newRequest("notfound", &notfound{s.nodeAddress, kind, id})
+
+	s.sendData(address, request)
+}
+
+func (s *Server) sendTx(addr string, tnx *transaction.Transaction) {
+	s.sendData(addr, txRequest(s.nodeAddress, tnx))
+}
+
+// SendTx relays a transaction to the node at addr
+func SendTx(addr string, tnx *transaction.Transaction) error {
+	return sendData(addr, txRequest("", tnx))
+}
+
+func txRequest(addrFrom string, tnx *transaction.Transaction) []byte {
+	return newRequest("tx", &tx{addrFrom, tnx.Serialize()})
+}
+
+func (s *Server) sendVersion(addr string) error {
+	bc := s.chain.bc
+	bestHeight, err := bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	pruned, err := bc.IsPruned()
+	if err != nil {
+		return err
+	}
+	request := newRequest("version", &verzion{params.Active.NodeVersion, bestHeight, s.nodeAddress, pruned || s.pruneDepth > 0, time.Now().Unix()})
+
+	s.sendData(addr, request)
+
+	return nil
+}
+
+func (s *Server) handleAddr(request []byte) error {
+	var payload addr
+	if err := decodeRequest(request, &payload); err != nil {
+		return err
+	}
+
+	fmt.Printf("There are %d known nodes now!\n", s.addPeers(payload.AddrList...))
+	s.requestBlocks()
+
+	return nil
+}
+
+func (s *Server) handleBlock(request []byte) error {
+	var payload block
+	if err := decodeRequest(request, &payload); err != nil {
+		return err
+	}
+
+	blockData := payload.Block
+	block, err := core.DeserializeBlock(blockData)
+	if err != nil {
+		return err
+	}
+
+	next, err := s.chain.bc.NextSnapshotBlock()
+	if err != nil {
+		return err
+	}
+	if next != nil && bytes.Equal(next, block.Hash) {
+		return s.validateSnapshotBlock(block, payload.AddrFrom)
+	}
+
+	fmt.Println("Recevied a new block!")
+	disconnected, connected, err := s.chain.addBlock(block)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		if err := s.pruneBlocks(); err != nil {
+			fmt.Println(err)
+		}
+
+		fmt.Printf("Added block %x\n", block.Hash)
+		if len(disconnected) > 0 {
+			fmt.Printf("Reorganized: %d blocks disconnected, %d connected\n", len(disconnected), len(connected))
+		}
+	}
+
+	if blockHash := s.nextBlockInTransit(); blockHash != nil {
+		s.sendGetData(payload.AddrFrom, "block", blockHash)
+	}
+
+	return nil
+}
+
+func (s *Server) handleInv(request []byte) error {
+	var payload inv
+	if err := decodeRequest(request, &payload); err != nil {
+		return err
+	}
+
+	fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
+
+	if payload.Type == "block" {
+		// Inventories list blocks from the tip down; request the missing ones
+		// parent first so every block can be connected as soon as it arrives
+		missing := [][]byte{}
+		for i := len(payload.Items) - 1; i >= 0; i-- {
+			if _, err := s.chain.bc.GetBlockHeader(payload.Items[i]); err != nil {
+				missing = append(missing, payload.Items[i])
+			}
+		}
+		s.setBlocksInTransit(missing)
+
+		if blockHash := s.nextBlockInTransit(); blockHash != nil {
+			s.sendGetData(payload.AddrFrom, "block", blockHash)
+		}
+	}
+
+	if payload.Type == "tx" && len(payload.Items) > 0 {
+		txID := payload.Items[0]
+
+		if _, ok := s.chain.transaction(txID); !ok {
+			s.sendGetData(payload.AddrFrom, "tx", txID)
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) handleGetBlocks(request []byte) error {
+	var payload getblocks
+	if err := decodeRequest(request, &payload); err != nil {
+		return err
+	}
+
+	blocks, err := s.chain.bc.GetBlockHashes()
+	if err != nil {
+		return err
+	}
+	s.sendInv(payload.AddrFrom, "block", blocks)
+
+	return nil
+}
+
+func (s *Server) handleGetData(request []byte) error {
+	var payload getdata
+	if err := decodeRequest(request, &payload); err != nil {
+		return err
+	}
+
+	if payload.Type == "block" {
+		block, err := s.chain.bc.GetBlock([]byte(payload.ID))
+		if err == core.ErrBlockNotFound || err == nil && block.IsPruned() {
+			s.sendNotFound(payload.AddrFrom, "block", payload.ID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Peers reject the blocks converted from gob, see core.ErrMigrated
+		migrated, err := s.chain.bc.IsMigrated(payload.ID)
+		if err != nil {
+			return err
+		}
+		if migrated {
+			s.sendNotFound(payload.AddrFrom, "block", payload.ID)
+			return nil
+		}
+
+		s.sendBlock(payload.AddrFrom, &block)
+	}
+
+	if payload.Type == "tx" {
+		tx, ok := s.chain.transaction(payload.ID)
+		if !ok {
+			s.sendNotFound(payload.AddrFrom, "tx", payload.ID)
+			return nil
+		}
+
+		s.sendTx(payload.AddrFrom, &tx)
+	}
+
+	return nil
+}
+
+func (s *Server) handleNotFound(request []byte) error {
+	var payload notfound
+	if err := decodeRequest(request, &payload); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s doesn't have %s %x\n", payload.AddrFrom, payload.Type, payload.ID)
+
+	// The blocks after a missing one can't be connected either
+	if payload.Type == "block" {
+		s.setBlocksInTransit(nil)
+	}
+
+	return nil
+}
+
+func (s *Server) handleTx(request []byte) error {
+	var payload tx
+	if err := decodeRequest(request, &payload); err != nil {
+		return err
+	}
+
+	txData := payload.Transaction
+	tx, err := transaction.DeserializeTransaction(txData)
+	if err != nil {
+		return err
+	}
+	if err := s.chain.addTransaction(tx); err != nil {
+		fmt.Printf("Rejected transaction %x: %v\n", tx.ID, err)
+		return nil
+	}
+
+	if s.isCentral() {
+		for _, node := range s.peers() {
+			if node != s.nodeAddress && node != payload.AddFrom {
+				s.sendInv(node, "tx", [][]byte{tx.ID})
+			}
+		}
+	} else if s.chain.mempoolSize() >= 2 && len(s.miningAddress) > 0 {
+		return s.mineTransactions()
+	}
+
+	return nil
+}
+
+// mineTransactions mines blocks with the mempool transactions until it is
+// empty or holds only invalid ones, announcing each block to the peers
+func (s *Server) mineTransactions() error {
+	for s.chain.mempoolSize() > 0 {
+		newBlock, err := s.chain.mine(s.miningAddress)
+		if err != nil {
+			return err
+		}
+		if newBlock == nil {
+			fmt.Println("All transactions are invalid! Waiting for new ones...")
+			return nil
+		}
+		if err := s.pruneBlocks(); err != nil {
+			return err
+		}
+
+		fmt.Println("New block is mined!")
+
+		for _, node := range s.peers() {
+			if node != s.nodeAddress {
+				s.sendInv(node, "block", [][]byte{newBlock.Hash})
+			}
+		}
+	}
+
+	return nil
+}
+
+// handleVersion handles a version request received from host
+func (s *Server) handleVersion(request []byte, host string) error {
+	var payload verzion
+	if err := decodeRequest(request, &payload); err != nil {
+		return err
+	}
+
+	s.chain.bc.AddTimeSample(host, payload.Timestamp)
+
+	myBestHeight, err := s.chain.bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	foreignerBestHeight := payload.BestHeight
+
+	if payload.Pruned {
+		fmt.Printf("%s is a pruned node\n", payload.AddrFrom)
+	} else if err := s.requestSnapshotBlock(payload.AddrFrom); err != nil {
+		return err
+	}
+
+	if myBestHeight < foreignerBestHeight {
+		s.sendGetBlocks(payload.AddrFrom)
+	} else if myBestHeight > foreignerBestHeight {
+		if err := s.sendVersion(payload.AddrFrom); err != nil {
+			return err
+		}
+	}
+
+	// sendAddr(payload.AddrFrom)
+	s.addPeers(payload.AddrFrom)
+
+	return nil
+}
+
+// requestSnapshotBlock asks the node at addr for the next historical block
+// the validation of a loaded UTXO snapshot needs, if any
+func (s *Server) requestSnapshotBlock(addr string) error {
+	next, err := s.chain.bc.NextSnapshotBlock()
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		s.sendGetData(addr, "block", next)
+	}
+
+	return nil
+}
+
+// validateSnapshotBlock validates a historical block of a loaded UTXO
+// snapshot and asks the node at addrFrom for the next one. A snapshot the
+// historical blocks contradict stops the node: its UTXO set can't be
+// trusted.
+func (s *Server) validateSnapshotBlock(block *core.Block, addrFrom string) error {
+	done, err := s.chain.validateSnapshotBlock(block)
+	if errors.Is(err, core.ErrSnapshotMismatch) {
+		s.stop(fmt.Errorf("%w, the blockchain DB must be rebuilt", err))
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if done {
+		fmt.Println("The UTXO snapshot is valid, all historical blocks are validated")
+		return nil
+	}
+	fmt.Printf("Validated historical block %d\n", block.Height)
+
+	return s.requestSnapshotBlock(addrFrom)
+}
+
+// stop makes Run return err
+func (s *Server) stop(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopErr == nil {
+		s.stopErr = err
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+}
+
+// pruneBlocks prunes the blocks buried deeper than the prune depth when the
+// node runs in pruned mode
+func (s *Server) pruneBlocks() error {
+	if s.pruneDepth == 0 {
+		return nil
+	}
+
+	pruned, err := s.chain.bc.Prune(s.pruneDepth)
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		fmt.Printf("Pruned %d blocks\n", pruned)
+	}
+
+	return nil
+}
+
+// handleConnection reads a request and handles it
+func (s *Server) handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	request, err := ioutil.ReadAll(conn)
+	if err != nil {
+		fmt.Printf("Can't read the request from %s: %v\n", conn.RemoteAddr(), err)
+		return
+	}
+	if len(request) < commandLength {
+		fmt.Printf("Request from %s is too short\n", conn.RemoteAddr())
+		return
+	}
+
+	// Peers are told apart by their host, the port changes with each
+	// connection
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		host = conn.RemoteAddr().String()
+	}
+
+	s.handleRequest(request, host)
+}
+
+// handleRequest handles a request another node sent from host. Malformed
+// requests and failures to handle them are reported without stopping the
+// node.
+func (s *Server) handleRequest(request []byte, host string) {
+	command := bytesToCommand(request[:commandLength])
+	fmt.Printf("Received %s command\n", command)
+
+	var err error
+	switch command {
+	case "addr":
+		err = s.handleAddr(request)
+	case "block":
+		err = s.handleBlock(request)
+	case "inv":
+		err = s.handleInv(request)
+	case "getblocks":
+		err = s.handleGetBlocks(request)
+	case "getdata":
+		err = s.handleGetData(request)
+	case "notfound":
+		err = s.handleNotFound(request)
+	case "tx":
+		err = s.handleTx(request)
+	case "version":
+		err = s.handleVersion(request, host)
+	default:
+		fmt.Println("Unknown command!")
+	}
+	if err != nil {
+		fmt.Printf("Can't handle %s command: %v\n", command, err)
+	}
+}
+
+// Run starts listening and serves other nodes. The historical blocks of a
+// loaded UTXO snapshot are fetched from peers and validated as the node
+// runs. It returns only when the node can't run, once the requests being
+// handled are done.
+func (s *Server) Run() error {
+	ln, err := net.Listen(protocol, s.nodeAddress)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+
+	if err := s.pruneBlocks(); err != nil {
+		return err
+	}
+
+	if peers := s.peers(); len(peers) > 0 && s.nodeAddress != peers[0] {
+		if err := s.sendVersion(peers[0]); err != nil {
+			return err
+		}
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.handlers.Wait()
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.stopErr != nil {
+				return s.stopErr
+			}
+			return err
+		}
+		s.handlers.Add(1)
+		go func() {
+			defer s.handlers.Done()
+			s.handleConnection(conn)
+		}()
+	}
+}
+
+// StartServer starts the node with the ID, listening at localhost. The
+// blockchain DB of the node is created if there is none yet. A positive
+// prune depth keeps only the transactions of that many recent blocks. It
+// returns only when the node can't run, and closes the chain once Run has
+// waited for the requests being handled.
+func StartServer(nodeID, minerAddress string, prune int) error {
+	bc, err := core.NewBlockchain(nodeID)
+	if errors.Is(err, core.ErrBlockchainNotFound) {
+		fmt.Printf("No existing blockchain found. Starting from the %s genesis block.\n", params.Active.Name)
+		bc, err = core.CreateBlockchain(nodeID)
+	}
+	if err != nil {
+		return err
+	}
+	defer bc.Close()
+
+	return NewServer(fmt.Sprintf("localhost:%s", nodeID), bc, minerAddress, prune).Run()
+}
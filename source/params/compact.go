@@ -0,0 +1,46 @@
+package params
+
+import "math/big"
+
+// CompactToBig converts a target in the compact "bits" format to a big.Int.
+// The top byte is the length of the target in bytes and the lower three
+// bytes are its most significant bytes. Negative targets decode to zero.
+func CompactToBig(bits uint32) *big.Int {
+	mantissa := int64(bits & 0x007fffff)
+	exponent := uint(bits >> 24)
+
+	if bits&0x00800000 != 0 {
+		return big.NewInt(0)
+	}
+
+	target := big.NewInt(mantissa)
+	if exponent <= 3 {
+		return target.Rsh(target, 8*(3-exponent))
+	}
+
+	return target.Lsh(target, 8*(exponent-3))
+}
+
+// BigToCompact converts a non-negative target to the compact "bits" format.
+// Precision beyond the three most significant bytes is lost.
+func BigToCompact(target *big.Int) uint32 {
+	if target.Sign() <= 0 {
+		return 0
+	}
+
+	var mantissa uint32
+	exponent := uint((target.BitLen() + 7) / 8)
+	if exponent <= 3 {
+		mantissa = uint32(target.Uint64()) << (8 * (3 - exponent))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(target, 8*(exponent-3)).Uint64())
+	}
+
+	// The sign bit of the mantissa must stay clear
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	return uint32(exponent<<24) | mantissa
+}
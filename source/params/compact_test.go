@@ -0,0 +1,20 @@
+package params
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompact(t *testing.T) {
+	target, _ := new(big.Int).SetString("00000000ffff0000000000000000000000000000000000000000000000000000", 16)
+
+	assert.Equal(t, 0, CompactToBig(0x1d00ffff).Cmp(target))
+	assert.Equal(t, uint32(0x1d00ffff), BigToCompact(target))
+
+	assert.Equal(t, 0, MainNet.PowLimit().Cmp(new(big.Int).Lsh(big.NewInt(1), 240)), "the mainnet powLimit is 2^240")
+	assert.Equal(t, uint32(0x02008000), BigToCompact(big.NewInt(0x80)), "the sign bit is kept clear")
+	assert.Equal(t, int64(0x80), CompactToBig(0x02008000).Int64())
+	assert.Equal(t, 0, CompactToBig(0x04923456).Sign(), "negative targets decode to zero")
+}
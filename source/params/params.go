@@ -0,0 +1,251 @@
+// Package params defines the networks a node can run on: their consensus
+// rules, address format and peers.
+package params
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+)
+
+// MaxMoney bounds every amount: output values, their sums, fees and block
+// subsidies. Sums checked against it can't overflow an int.
+const MaxMoney = 21000000
+
+// ChainParams defines a network: its consensus rules, storage, address
+// format and peers. Nodes only get along with nodes using the same
+// parameters.
+type ChainParams struct {
+	Name string
+	// DBFile is the name of the blockchain DB file, formatted with the node ID
+	DBFile string
+	// GenesisCoinbaseData is the data of the genesis coinbase input
+	GenesisCoinbaseData string
+	// GenesisTimestamp is the timestamp of the genesis block
+	GenesisTimestamp int64
+	// PremineFile is the path of a JSON file listing the allocations the
+	// genesis block pays, as [{"Address": ADDRESS, "Amount": AMOUNT}, ...].
+	// Without one the genesis subsidy can't be spent.
+	PremineFile string
+	// GenesisHash pins the hash of the genesis block, as hex. Nodes refuse to
+	// run when the genesis they build or find in their DB has another hash.
+	GenesisHash string
+	// InitialSubsidy is the block subsidy, which halves every
+	// SubsidyHalvingInterval blocks
+	InitialSubsidy         int
+	SubsidyHalvingInterval int
+	// CoinbaseMaturity is the number of blocks a coinbase output must be
+	// buried under before it can be spent: a coinbase of the block at height
+	// h can be spent from height h+CoinbaseMaturity on
+	CoinbaseMaturity int
+	// PowLimitBits is the easiest allowed target in compact form. The genesis
+	// block is mined with it.
+	PowLimitBits uint32
+	// RetargetInterval is the number of blocks between difficulty changes
+	RetargetInterval int
+	// TargetBlockTime is the desired number of seconds between blocks
+	TargetBlockTime int64
+	// AddressVersion is the version byte addresses start with
+	AddressVersion byte
+	// KnownNodes lists the nodes to connect to at startup. The first one is
+	// the central node.
+	KnownNodes  []string
+	NodeVersion int
+	// Checkpoints lists the main chain blocks pinned by hash, in ascending
+	// height order
+	Checkpoints []Checkpoint
+	// UTXOSnapshots lists the UTXO snapshots a new node may start from
+	// instead of replaying the chain
+	UTXOSnapshots []UTXOSnapshot
+}
+
+// Checkpoint pins the hash of the main chain block at a height
+type Checkpoint struct {
+	Height int
+	Hash   string
+}
+
+// UTXOSnapshot pins the UTXO set left by the main chain up to a block, as
+// written by the dumputxo command
+type UTXOSnapshot struct {
+	Height int
+	// BlockHash is the hash of the main chain block at the height, as hex
+	BlockHash string
+	// ContentHash is the hash of the unspent outputs, as hex
+	ContentHash string
+}
+
+// MainNet holds the parameters of the main network
+var MainNet = ChainParams{
+	Name:                   "mainnet",
+	DBFile:                 "blockchain_%s.db",
+	GenesisCoinbaseData:    "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks",
+	GenesisTimestamp:       1231006505,
+	GenesisHash:            "000065e1c8a574a76f8027fa6375a78e63c65c15dd6036fd4eef2fcae591c47b",
+	InitialSubsidy:         10,
+	SubsidyHalvingInterval: 100,
+	CoinbaseMaturity:       100,
+	PowLimitBits:           0x1f010000, // 2^240
+	RetargetInterval:       10,
+	TargetBlockTime:        10,
+	AddressVersion:         0x00,
+	KnownNodes:             []string{"localhost:3000"},
+	NodeVersion:            3,
+	Checkpoints:            []Checkpoint{{Height: 0, Hash: "000065e1c8a574a76f8027fa6375a78e63c65c15dd6036fd4eef2fcae591c47b"}},
+}
+
+// TestNet holds the parameters of the public test network
+var TestNet = ChainParams{
+	Name:                   "testnet",
+	DBFile:                 "blockchain_testnet_%s.db",
+	GenesisCoinbaseData:    "Test network genesis",
+	GenesisTimestamp:       1296688602,
+	GenesisHash:            "00005a855c6c2d2d4ce74d8c7c4c4eb23b12f8a1fc13cf43d11ac6c5952eac3e",
+	InitialSubsidy:         10,
+	SubsidyHalvingInterval: 100,
+	CoinbaseMaturity:       100,
+	PowLimitBits:           0x1f010000,
+	RetargetInterval:       10,
+	TargetBlockTime:        10,
+	AddressVersion:         0x6f,
+	KnownNodes:             []string{"localhost:13000"},
+	NodeVersion:            3,
+	Checkpoints:            []Checkpoint{{Height: 0, Hash: "00005a855c6c2d2d4ce74d8c7c4c4eb23b12f8a1fc13cf43d11ac6c5952eac3e"}},
+}
+
+// RegTest holds the parameters of a private regression test network,
+// where blocks are mined almost instantly
+var RegTest = ChainParams{
+	Name:                   "regtest",
+	DBFile:                 "blockchain_regtest_%s.db",
+	GenesisCoinbaseData:    "Regression test network genesis",
+	GenesisTimestamp:       1296688602,
+	GenesisHash:            "7fa34a812c734afa22d631272dbf7edd7d2746b4b4a5b29640f5d84de18624e3",
+	InitialSubsidy:         10,
+	SubsidyHalvingInterval: 150,
+	CoinbaseMaturity:       10,
+	PowLimitBits:           0x207fffff,
+	RetargetInterval:       10,
+	TargetBlockTime:        10,
+	AddressVersion:         0x6f,
+	KnownNodes:             []string{"localhost:23000"},
+	NodeVersion:            3,
+	Checkpoints:            []Checkpoint{{Height: 0, Hash: "7fa34a812c734afa22d631272dbf7edd7d2746b4b4a5b29640f5d84de18624e3"}},
+}
+
+// Active points to the parameters of the network the node runs on
+var Active = &MainNet
+
+// PowLimit returns the easiest allowed target
+func (p *ChainParams) PowLimit() *big.Int {
+	return CompactToBig(p.PowLimitBits)
+}
+
+// PinnedGenesisHash returns the pinned genesis hash, or nil when it isn't
+// pinned
+func (p *ChainParams) PinnedGenesisHash() []byte {
+	hash, err := hex.DecodeString(p.GenesisHash)
+	if err != nil || len(hash) == 0 {
+		return nil
+	}
+
+	return hash
+}
+
+// validate checks that the parameters can run a network
+func (p *ChainParams) validate() error {
+	switch {
+	case p.Name == "":
+		return errors.New("the network has no name")
+	case strings.Count(p.DBFile, "%s") != 1:
+		return errors.New("DBFile must contain %s exactly once, for the node ID")
+	case p.InitialSubsidy < 0 || p.SubsidyHalvingInterval <= 0:
+		return errors.New("the subsidy must be non-negative and halve after a positive number of blocks")
+	case p.InitialSubsidy > MaxMoney:
+		return fmt.Errorf("the subsidy must not exceed %d", MaxMoney)
+	case p.CoinbaseMaturity < 0:
+		return errors.New("CoinbaseMaturity must not be negative")
+	case p.PowLimit().Sign() <= 0:
+		return errors.New("PowLimitBits must be a positive target")
+	case p.RetargetInterval <= 0 || p.TargetBlockTime <= 0:
+		return errors.New("RetargetInterval and TargetBlockTime must be positive")
+	case len(p.KnownNodes) == 0:
+		return errors.New("at least one known node is needed")
+	}
+
+	if _, err := hex.DecodeString(p.GenesisHash); err != nil {
+		return fmt.Errorf("GenesisHash: %v", err)
+	}
+
+	for i, cp := range p.Checkpoints {
+		if _, err := hex.DecodeString(cp.Hash); err != nil {
+			return fmt.Errorf("checkpoint at height %d: %v", cp.Height, err)
+		}
+		if i > 0 && cp.Height <= p.Checkpoints[i-1].Height {
+			return errors.New("checkpoints must be in ascending height order")
+		}
+	}
+
+	for _, snapshot := range p.UTXOSnapshots {
+		if _, err := hex.DecodeString(snapshot.BlockHash); err != nil {
+			return fmt.Errorf("UTXO snapshot at height %d: %v", snapshot.Height, err)
+		}
+		if _, err := hex.DecodeString(snapshot.ContentHash); err != nil {
+			return fmt.Errorf("UTXO snapshot at height %d: %v", snapshot.Height, err)
+		}
+	}
+
+	return nil
+}
+
+// LoadChainParams reads custom network parameters from a JSON file. The
+// fields are named as in ChainParams.
+func LoadChainParams(path string) (*ChainParams, error) {
+	var p ChainParams
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return &p, nil
+}
+
+// SelectNetwork makes the node run on a built-in network, given by name, or
+// on the network described by a JSON file
+func SelectNetwork(network string) error {
+	var p *ChainParams
+	switch network {
+	case MainNet.Name:
+		p = &MainNet
+	case TestNet.Name:
+		p = &TestNet
+	case RegTest.Name:
+		p = &RegTest
+	default:
+		var err error
+		if p, err = LoadChainParams(network); err != nil {
+			return err
+		}
+	}
+
+	// The built-in networks are checked like the loaded ones
+	if err := p.validate(); err != nil {
+		return fmt.Errorf("%s: %v", network, err)
+	}
+	Active = p
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package params
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectNetwork(t *testing.T) {
+	saved := Active
+	defer func() { Active = saved }()
+
+	assert.NoError(t, SelectNetwork("testnet"))
+	assert.Equal(t, &TestNet, Active)
+
+	assert.NoError(t, SelectNetwork("mainnet"))
+	assert.Equal(t, &MainNet, Active)
+
+	custom := filepath.Join(t.TempDir(), "custom.json")
+	err := ioutil.WriteFile(custom, []byte(`{
+		"Name": "custom",
+		"DBFile": "blockchain_custom_%s.db",
+		"InitialSubsidy": 50,
+		"SubsidyHalvingInterval": 1000,
+		"PowLimitBits": 545259519,
+		"RetargetInterval": 20,
+		"TargetBlockTime": 60,
+		"AddressVersion": 42,
+		"KnownNodes": ["localhost:4000"],
+		"Checkpoints": [{"Height": 5, "Hash": "00ff"}],
+		"UTXOSnapshots": [{"Height": 5, "BlockHash": "00ff", "ContentHash": "abcd"}]
+	}`), 0644)
+	assert.NoError(t, err)
+
+	assert.NoError(t, SelectNetwork(custom))
+	assert.Equal(t, "custom", Active.Name)
+	assert.Equal(t, 50, Active.InitialSubsidy)
+	assert.Equal(t, byte(42), Active.AddressVersion)
+	assert.Equal(t, []Checkpoint{{5, "00ff"}}, Active.Checkpoints)
+	assert.Equal(t, []UTXOSnapshot{{5, "00ff", "abcd"}}, Active.UTXOSnapshots)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	assert.NoError(t, ioutil.WriteFile(invalid, []byte(`{"Name": "invalid", "DBFile": "chain.db"}`), 0644))
+	assert.Error(t, SelectNetwork(invalid))
+	assert.Error(t, SelectNetwork("unknown"))
+
+	checkpoints := RegTest.Checkpoints
+	RegTest.Checkpoints = []Checkpoint{{Height: 0, Hash: "not hex"}}
+	assert.Error(t, SelectNetwork("regtest"), "the built-in networks are checked too")
+	RegTest.Checkpoints = checkpoints
+	assert.Equal(t, "custom", Active.Name, "a failed selection keeps the network")
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"github.com/boltdb/bolt"
+)
+
+// boltStorage keeps the chain in a bolt DB file
+type boltStorage struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens the bolt DB file at path, creating it if needed
+func OpenBolt(path string) (Storage, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &boltStorage{db}, nil
+}
+
+func (s *boltStorage) View(fn func(tx Tx) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
+
+func (s *boltStorage) Update(fn func(tx Tx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
+
+func (s *boltStorage) Close() error {
+	return s.db.Close()
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+func (t boltTx) Bucket(name []byte) Bucket {
+	b := t.tx.Bucket(name)
+	if b == nil {
+		return nil
+	}
+
+	return boltBucket{b}
+}
+
+func (t boltTx) CreateBucket(name []byte) (Bucket, error) {
+	b, err := t.tx.CreateBucket(name)
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	return boltBucket{b}, nil
+}
+
+func (t boltTx) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	b, err := t.tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, boltError(err)
+	}
+
+	return boltBucket{b}, nil
+}
+
+func (t boltTx) DeleteBucket(name []byte) error {
+	return boltError(t.tx.DeleteBucket(name))
+}
+
+type boltBucket struct {
+	*bolt.Bucket
+}
+
+func (b boltBucket) Put(key, value []byte) error {
+	return boltError(b.Bucket.Put(key, value))
+}
+
+func (b boltBucket) Delete(key []byte) error {
+	return boltError(b.Bucket.Delete(key))
+}
+
+func (b boltBucket) Cursor() Cursor {
+	return b.Bucket.Cursor()
+}
+
+// boltError translates the bolt errors callers check for
+func boltError(err error) error {
+	switch err {
+	case bolt.ErrBucketNotFound:
+		return ErrBucketNotFound
+	case bolt.ErrBucketExists:
+		return ErrBucketExists
+	case bolt.ErrTxNotWritable:
+		return ErrTxNotWritable
+	}
+
+	return err
+}
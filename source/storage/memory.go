@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// memoryStorage keeps the chain in memory. Transactions work on copies of
+// the buckets they touch, which replace the originals on commit. Updates are
+// serialized; views run concurrently with each other.
+type memoryStorage struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemory returns an empty in-memory storage
+func NewMemory() Storage {
+	return &memoryStorage{buckets: make(map[string]map[string][]byte)}
+}
+
+func (s *memoryStorage) View(fn func(tx Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return fn(&memoryTx{s.buckets, nil})
+}
+
+func (s *memoryStorage) Update(fn func(tx Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets := make(map[string]map[string][]byte, len(s.buckets))
+	for name, b := range s.buckets {
+		buckets[name] = b
+	}
+
+	tx := &memoryTx{buckets, make(map[string]bool)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.buckets = tx.buckets
+
+	return nil
+}
+
+func (s *memoryStorage) Close() error {
+	return nil
+}
+
+// memoryTx is a transaction of a memoryStorage. copied is nil for views and
+// otherwise records the buckets the transaction has its own copy of.
+type memoryTx struct {
+	buckets map[string]map[string][]byte
+	copied  map[string]bool
+}
+
+func (t *memoryTx) Bucket(name []byte) Bucket {
+	if _, ok := t.buckets[string(name)]; !ok {
+		return nil
+	}
+
+	return &memoryBucket{t, string(name)}
+}
+
+func (t *memoryTx) CreateBucket(name []byte) (Bucket, error) {
+	if t.copied == nil {
+		return nil, ErrTxNotWritable
+	}
+	if _, ok := t.buckets[string(name)]; ok {
+		return nil, ErrBucketExists
+	}
+
+	t.buckets[string(name)] = make(map[string][]byte)
+	t.copied[string(name)] = true
+
+	return &memoryBucket{t, string(name)}, nil
+}
+
+func (t *memoryTx) CreateBucketIfNotExists(name []byte) (Bucket, error) {
+	if b := t.Bucket(name); b != nil {
+		return b, nil
+	}
+
+	return t.CreateBucket(name)
+}
+
+func (t *memoryTx) DeleteBucket(name []byte) error {
+	if t.copied == nil {
+		return ErrTxNotWritable
+	}
+	if _, ok := t.buckets[string(name)]; !ok {
+		return ErrBucketNotFound
+	}
+
+	delete(t.buckets, string(name))
+
+	return nil
+}
+
+type memoryBucket struct {
+	tx   *memoryTx
+	name string
+}
+
+func (b *memoryBucket) Get(key []byte) []byte {
+	return b.tx.buckets[b.name][string(key)]
+}
+
+// writableMap returns the map of the bucket, copying the committed one on the
+// first write of the transaction
+func (b *memoryBucket) writableMap() (map[string][]byte, error) {
+	if b.tx.copied == nil {
+		return nil, ErrTxNotWritable
+	}
+
+	m := b.tx.buckets[b.name]
+	if !b.tx.copied[b.name] {
+		copied := make(map[string][]byte, len(m))
+		for k, v := range m {
+			copied[k] = v
+		}
+		b.tx.buckets[b.name] = copied
+		b.tx.copied[b.name] = true
+		m = copied
+	}
+
+	return m, nil
+}
+
+func (b *memoryBucket) Put(key, value []byte) error {
+	m, err := b.writableMap()
+	if err != nil {
+		return err
+	}
+
+	m[string(key)] = append([]byte{}, value...)
+
+	return nil
+}
+
+func (b *memoryBucket) Delete(key []byte) error {
+	m, err := b.writableMap()
+	if err != nil {
+		return err
+	}
+
+	delete(m, string(key))
+
+	return nil
+}
+
+func (b *memoryBucket) Cursor() Cursor {
+	m := b.tx.buckets[b.name]
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return &memoryCursor{m, keys, 0}
+}
+
+// memoryCursor walks a snapshot of the keys of a bucket taken when it was
+// created
+type memoryCursor struct {
+	bucket map[string][]byte
+	keys   []string
+	pos    int
+}
+
+func (c *memoryCursor) current() ([]byte, []byte) {
+	if c.pos >= len(c.keys) {
+		return nil, nil
+	}
+
+	key := c.keys[c.pos]
+	return []byte(key), c.bucket[key]
+}
+
+func (c *memoryCursor) First() ([]byte, []byte) {
+	c.pos = 0
+	return c.current()
+}
+
+func (c *memoryCursor) Next() ([]byte, []byte) {
+	c.pos++
+	return c.current()
+}
+
+func (c *memoryCursor) Seek(seek []byte) ([]byte, []byte) {
+	c.pos = sort.Search(len(c.keys), func(i int) bool {
+		return bytes.Compare([]byte(c.keys[i]), seek) >= 0
+	})
+	return c.current()
+}
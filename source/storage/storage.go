@@ -0,0 +1,65 @@
+// Package storage abstracts the key/value store the blockchain is kept in,
+// with a bolt backend for nodes and an in-memory one for tests.
+package storage
+
+import "errors"
+
+// The chain is kept in a Storage as buckets of key-value pairs:
+//
+//   - blocks, headers and chainwork hold every known block by hash, and the
+//     "l" key of blocks holds the hash of the main chain tip
+//   - chainstate holds the UTXO set and undo the undo data of main chain
+//     blocks
+//   - txindex, heightindex and the optional addrindex index the main chain
+//   - prune holds the metadata of pruned nodes
+//
+// Each View or Update sees a consistent snapshot, and an Update whose
+// function returns an error leaves the storage unchanged.
+
+// ErrBucketNotFound is returned when deleting a bucket that doesn't exist
+var ErrBucketNotFound = errors.New("bucket not found")
+
+// ErrBucketExists is returned when creating a bucket that already exists
+var ErrBucketExists = errors.New("bucket already exists")
+
+// ErrTxNotWritable is returned when modifying the storage in a View
+var ErrTxNotWritable = errors.New("storage transaction is not writable")
+
+// Storage is a transactional key-value store organized in buckets
+type Storage interface {
+	// View runs fn in a read-only transaction
+	View(fn func(tx Tx) error) error
+	// Update runs fn in a read-write transaction, which is committed when fn
+	// returns nil and rolled back otherwise
+	Update(fn func(tx Tx) error) error
+	Close() error
+}
+
+// Tx is a transaction of a Storage
+type Tx interface {
+	// Bucket returns the bucket with the given name, or nil if it doesn't
+	// exist
+	Bucket(name []byte) Bucket
+	CreateBucket(name []byte) (Bucket, error)
+	CreateBucketIfNotExists(name []byte) (Bucket, error)
+	DeleteBucket(name []byte) error
+}
+
+// Bucket is a collection of key-value pairs ordered by key. Values returned
+// by Get are only valid until the end of the transaction.
+type Bucket interface {
+	// Get returns the value of the key, or nil if it isn't set
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	Cursor() Cursor
+}
+
+// Cursor walks the keys of a bucket in byte order. Each method returns a nil
+// key once the end of the bucket is reached.
+type Cursor interface {
+	First() (key, value []byte)
+	Next() (key, value []byte)
+	// Seek moves to the first key not less than seek
+	Seek(seek []byte) (key, value []byte)
+}
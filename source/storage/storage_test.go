@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// forEachStorage runs the test against every storage backend
+func forEachStorage(t *testing.T, test func(t *testing.T, s Storage)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemory())
+	})
+
+	t.Run("bolt", func(t *testing.T) {
+		s, err := OpenBolt(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		test(t, s)
+	})
+}
+
+func TestStorageUpdate(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		err := s.Update(func(tx Tx) error {
+			b, err := tx.CreateBucket([]byte("b"))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte("k"), []byte("v"))
+		})
+		assert.Nil(t, err)
+
+		// A failed update leaves nothing behind
+		failure := errors.New("failure")
+		err = s.Update(func(tx Tx) error {
+			b := tx.Bucket([]byte("b"))
+			b.Put([]byte("k"), []byte("changed"))
+			b.Put([]byte("other"), []byte("v"))
+			tx.CreateBucket([]byte("c"))
+			return failure
+		})
+		assert.Equal(t, failure, err)
+
+		s.View(func(tx Tx) error {
+			b := tx.Bucket([]byte("b"))
+			assert.Equal(t, []byte("v"), b.Get([]byte("k")))
+			assert.Nil(t, b.Get([]byte("other")))
+			assert.Nil(t, tx.Bucket([]byte("c")))
+			return nil
+		})
+
+		err = s.Update(func(tx Tx) error {
+			_, err := tx.CreateBucket([]byte("b"))
+			assert.Equal(t, ErrBucketExists, err)
+
+			assert.Equal(t, ErrBucketNotFound, tx.DeleteBucket([]byte("missing")))
+			return tx.DeleteBucket([]byte("b"))
+		})
+		assert.Nil(t, err)
+
+		s.View(func(tx Tx) error {
+			assert.Nil(t, tx.Bucket([]byte("b")))
+			return nil
+		})
+	})
+}
+
+func TestStorageView(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		s.Update(func(tx Tx) error {
+			_, err := tx.CreateBucket([]byte("b"))
+			return err
+		})
+
+		s.View(func(tx Tx) error {
+			_, err := tx.CreateBucket([]byte("c"))
+			assert.Equal(t, ErrTxNotWritable, err)
+			assert.Equal(t, ErrTxNotWritable, tx.Bucket([]byte("b")).Put([]byte("k"), []byte("v")))
+			return nil
+		})
+	})
+}
+
+func TestStorageCursor(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, s Storage) {
+		s.Update(func(tx Tx) error {
+			b, _ := tx.CreateBucket([]byte("b"))
+			for _, k := range []string{"c", "a", "e", "b"} {
+				b.Put([]byte(k), []byte(k+k))
+			}
+			return nil
+		})
+
+		s.View(func(tx Tx) error {
+			var keys []string
+			c := tx.Bucket([]byte("b")).Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				assert.Equal(t, string(k)+string(k), string(v))
+				keys = append(keys, string(k))
+			}
+			assert.Equal(t, []string{"a", "b", "c", "e"}, keys)
+
+			k, _ := c.Seek([]byte("d"))
+			assert.Equal(t, []byte("e"), k)
+			k, _ = c.Next()
+			assert.Nil(t, k)
+			k, _ = c.Seek([]byte("f"))
+			assert.Nil(t, k)
+			return nil
+		})
+	})
+}
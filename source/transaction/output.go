@@ -0,0 +1,102 @@
+package transaction
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+// TXOutput represents a transaction output
+type TXOutput struct {
+	Value      int
+	PubKeyHash []byte
+}
+
+// Lock signs the output
+func (out *TXOutput) Lock(address []byte) {
+	pubKeyHash := wallet.Base58Decode(address)
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+	out.PubKeyHash = pubKeyHash
+}
+
+// IsLockedWithKey checks if the output can be used by the owner of the pubkey
+func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
+	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
+}
+
+// NewTXOutput create a new TXOutput
+func NewTXOutput(value int, address string) *TXOutput {
+	txo := &TXOutput{value, nil}
+	txo.Lock([]byte(address))
+
+	return txo
+}
+
+// TXOutputs collects the unspent outputs of a transaction, keyed by their
+// index in the transaction, along with the height of the block that created
+// them and whether they were created by a coinbase
+type TXOutputs struct {
+	Outputs  map[int]TXOutput
+	Height   int
+	Coinbase bool
+}
+
+// IsMature checks whether the outputs can be spent in a block at the given
+// height. Only coinbase outputs have to mature.
+func (outs TXOutputs) IsMature(spendHeight int) bool {
+	return !outs.Coinbase || spendHeight-outs.Height >= params.Active.CoinbaseMaturity
+}
+
+// Serialize returns the canonical encoding of TXOutputs:
+//
+//	Height       varint
+//	Coinbase     bool
+//	len(Outputs) uvarint
+//	Outputs      for each output, by ascending index: index varint,
+//	             Value varint, PubKeyHash bytes
+func (outs TXOutputs) Serialize() []byte {
+	var w utils.Writer
+
+	indexes := make([]int, 0, len(outs.Outputs))
+	for i := range outs.Outputs {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	w.Varint(int64(outs.Height))
+	w.Bool(outs.Coinbase)
+	w.Uvarint(uint64(len(indexes)))
+	for _, i := range indexes {
+		w.Varint(int64(i))
+		w.Varint(int64(outs.Outputs[i].Value))
+		w.VarBytes(outs.Outputs[i].PubKeyHash)
+	}
+
+	return w.Data()
+}
+
+// DeserializeOutputs decodes TXOutputs from their canonical encoding
+func DeserializeOutputs(data []byte) (TXOutputs, error) {
+	r := utils.NewReader(data)
+	outs := TXOutputs{Outputs: make(map[int]TXOutput), Height: r.Int(), Coinbase: r.Bool()}
+
+	last := -1
+	for n := r.Count(); n > 0; n-- {
+		i := r.Int()
+		if i <= last {
+			return TXOutputs{}, fmt.Errorf("outputs: %w", utils.ErrMalformed)
+		}
+		outs.Outputs[i] = TXOutput{r.Int(), r.VarBytes()}
+		last = i
+	}
+
+	if err := r.Finish(); err != nil {
+		return TXOutputs{}, fmt.Errorf("outputs: %w", err)
+	}
+
+	return outs, nil
+}
@@ -0,0 +1,263 @@
+// Package transaction defines transactions, their inputs and outputs, and
+// how inputs are signed and verified.
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"strings"
+
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
+)
+
+// ErrInvalidSignature is returned when an input isn't signed by the owner of
+// the output it spends
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrMissingInput is returned when an input spends an output that doesn't
+// exist or was already spent
+var ErrMissingInput = errors.New("input spends a missing or already spent output")
+
+// Transaction represents a Bitcoin transaction
+type Transaction struct {
+	ID   []byte
+	Vin  []TXInput
+	Vout []TXOutput
+}
+
+// IsCoinbase checks whether the transaction is coinbase
+func (tx Transaction) IsCoinbase() bool {
+	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
+}
+
+// Serialize returns the canonical encoding of the transaction:
+//
+//	ID        bytes
+//	len(Vin)  uvarint
+//	Vin       for each input: Txid bytes, Vout varint, Signature bytes,
+//	          PubKey bytes
+//	len(Vout) uvarint
+//	Vout      for each output: Value varint, PubKeyHash bytes
+//
+// The primitives are described in package utils.
+func (tx Transaction) Serialize() []byte {
+	var w utils.Writer
+
+	w.VarBytes(tx.ID)
+	w.Uvarint(uint64(len(tx.Vin)))
+	for _, vin := range tx.Vin {
+		w.VarBytes(vin.Txid)
+		w.Varint(int64(vin.Vout))
+		w.VarBytes(vin.Signature)
+		w.VarBytes(vin.PubKey)
+	}
+	w.Uvarint(uint64(len(tx.Vout)))
+	for _, out := range tx.Vout {
+		w.Varint(int64(out.Value))
+		w.VarBytes(out.PubKeyHash)
+	}
+
+	return w.Data()
+}
+
+// Hash returns the hash of the Transaction: the SHA-256 of its encoding with
+// an empty ID
+func (tx *Transaction) Hash() []byte {
+	var hash [32]byte
+
+	txCopy := *tx
+	txCopy.ID = []byte{}
+
+	hash = sha256.Sum256(txCopy.Serialize())
+
+	return hash[:]
+}
+
+// Sign signs each input of a Transaction. prevTXs must hold the
+// transactions the inputs spend, by hex ID. An input signs the hash of a
+// trimmed copy of the transaction in which only that input carries the
+// public key hash of the output it spends. The signature is r followed by s,
+// each padded to the size of the curve.
+func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	if err := checkPrevTransactions(tx, prevTXs); err != nil {
+		return err
+	}
+
+	txCopy := tx.TrimmedCopy()
+
+	for inID, vin := range txCopy.Vin {
+		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+		txCopy.Vin[inID].Signature = nil
+		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
+
+		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.Hash())
+		if err != nil {
+			return err
+		}
+		size := privKey.Curve.Params().BitSize / 8
+		signature := append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+
+		tx.Vin[inID].Signature = signature
+		txCopy.Vin[inID].PubKey = nil
+	}
+
+	return nil
+}
+
+// checkPrevTransactions checks that prevTXs holds the outputs spent by the
+// inputs of tx
+func checkPrevTransactions(tx *Transaction, prevTXs map[string]Transaction) error {
+	for _, vin := range tx.Vin {
+		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+		if prevTx.ID == nil || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return fmt.Errorf("%w: %x:%d", ErrMissingInput, vin.Txid, vin.Vout)
+		}
+	}
+
+	return nil
+}
+
+// String returns a human-readable representation of a transaction
+func (tx Transaction) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.ID))
+
+	for i, input := range tx.Vin {
+
+		lines = append(lines, fmt.Sprintf("     Input %d:", i))
+		lines = append(lines, fmt.Sprintf("       TXID:      %x", input.Txid))
+		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
+		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
+		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+	}
+
+	for i := range tx.Vout {
+		lines = append(lines, fmt.Sprintf("     Output %d:", i))
+		lines = append(lines, fmt.Sprintf("       Value:  %d", tx.Vout[i].Value))
+		lines = append(lines, fmt.Sprintf("       Script: %x", tx.Vout[i].PubKeyHash))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// TrimmedCopy creates a trimmed copy of Transaction to be used in signing
+func (tx *Transaction) TrimmedCopy() Transaction {
+	var inputs []TXInput
+	var outputs []TXOutput
+
+	for _, vin := range tx.Vin {
+		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil})
+	}
+
+	for i := range tx.Vout {
+		outputs = append(outputs, TXOutput{tx.Vout[i].Value, tx.Vout[i].PubKeyHash})
+	}
+
+	txCopy := Transaction{tx.ID, inputs, outputs}
+
+	return txCopy
+}
+
+// Verify verifies signatures of Transaction inputs. ErrInvalidSignature is
+// returned for the first input with a bad signature or a key other than the
+// one the spent output is locked with.
+func (tx *Transaction) Verify(prevTXs map[string]Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	if err := checkPrevTransactions(tx, prevTXs); err != nil {
+		return err
+	}
+
+	txCopy := tx.TrimmedCopy()
+	curve := elliptic.P256()
+
+	for inID, vin := range tx.Vin {
+		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+		if !vin.UsesKey(prevTx.Vout[vin.Vout].PubKeyHash) {
+			return fmt.Errorf("%w: input %d of transaction %x uses another key", ErrInvalidSignature, inID, tx.ID)
+		}
+		txCopy.Vin[inID].Signature = nil
+		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
+
+		r := big.Int{}
+		s := big.Int{}
+		sigLen := len(vin.Signature)
+		r.SetBytes(vin.Signature[:(sigLen / 2)])
+		s.SetBytes(vin.Signature[(sigLen / 2):])
+
+		x := big.Int{}
+		y := big.Int{}
+		keyLen := len(vin.PubKey)
+		x.SetBytes(vin.PubKey[:(keyLen / 2)])
+		y.SetBytes(vin.PubKey[(keyLen / 2):])
+
+		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
+		if ecdsa.Verify(&rawPubKey, txCopy.Hash(), &r, &s) == false {
+			return fmt.Errorf("%w: input %d of transaction %x", ErrInvalidSignature, inID, tx.ID)
+		}
+		txCopy.Vin[inID].PubKey = nil
+	}
+
+	return nil
+}
+
+// NewCoinbaseTX creates a new coinbase transaction paying value to the miner.
+// Random data is used when data is empty.
+func NewCoinbaseTX(to, data string, value int) (*Transaction, error) {
+	if data == "" {
+		randData := make([]byte, 20)
+		_, err := rand.Read(randData)
+		if err != nil {
+			return nil, err
+		}
+
+		data = fmt.Sprintf("%x", randData)
+	}
+
+	txin := TXInput{[]byte{}, -1, nil, []byte(data)}
+	txout := NewTXOutput(value, to)
+	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}}
+	tx.ID = tx.Hash()
+
+	return &tx, nil
+}
+
+// DeserializeTransaction decodes a transaction from its canonical encoding
+func DeserializeTransaction(data []byte) (Transaction, error) {
+	var tx Transaction
+
+	r := utils.NewReader(data)
+	tx.ID = r.VarBytes()
+	if n := r.Count(); n > 0 {
+		tx.Vin = make([]TXInput, n)
+		for i := range tx.Vin {
+			tx.Vin[i] = TXInput{r.VarBytes(), r.Int(), r.VarBytes(), r.VarBytes()}
+		}
+	}
+	if n := r.Count(); n > 0 {
+		tx.Vout = make([]TXOutput, n)
+		for i := range tx.Vout {
+			tx.Vout[i] = TXOutput{r.Int(), r.VarBytes()}
+		}
+	}
+
+	if err := r.Finish(); err != nil {
+		return Transaction{}, fmt.Errorf("transaction: %w", err)
+	}
+
+	return tx, nil
+}
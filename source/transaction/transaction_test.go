@@ -0,0 +1,53 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/wallet"
+)
+
+func TestSerialize(t *testing.T) {
+	w, err := wallet.NewWallet()
+	assert.NoError(t, err)
+	coinbase, err := NewCoinbaseTX(string(w.GetAddress()), "data", 10)
+	assert.NoError(t, err)
+
+	data := coinbase.Serialize()
+	decoded, err := DeserializeTransaction(data)
+	assert.NoError(t, err)
+	assert.Equal(t, coinbase.ID, decoded.ID)
+	assert.True(t, decoded.IsCoinbase())
+	assert.Equal(t, data, decoded.Serialize(), "the encoding is deterministic")
+	assert.Equal(t, coinbase.ID, decoded.Hash())
+
+	_, err = DeserializeTransaction(append(data, 0))
+	assert.Error(t, err, "trailing bytes are rejected")
+}
+
+func TestSignVerify(t *testing.T) {
+	var tx Transaction
+	var prevTXs map[string]Transaction
+
+	// Keys and signature values shorter than the curve size are padded
+	for i := 0; i < 20; i++ {
+		w, err := wallet.NewWallet()
+		assert.NoError(t, err)
+		prev, err := NewCoinbaseTX(string(w.GetAddress()), "", 10)
+		assert.NoError(t, err)
+		prevTXs = map[string]Transaction{hex.EncodeToString(prev.ID): *prev}
+
+		tx = Transaction{nil, []TXInput{{prev.ID, 0, nil, w.PublicKey}}, []TXOutput{*NewTXOutput(10, string(w.GetAddress()))}}
+		tx.ID = tx.Hash()
+		assert.NoError(t, tx.Sign(w.PrivateKey, prevTXs))
+		assert.Len(t, w.PublicKey, 64)
+		assert.Len(t, tx.Vin[0].Signature, 64)
+		assert.NoError(t, tx.Verify(prevTXs))
+	}
+
+	tx.Vout[0].Value++
+	assert.True(t, errors.Is(tx.Verify(prevTXs), ErrInvalidSignature), "signatures commit to the outputs")
+}
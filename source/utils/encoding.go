@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// The canonical encoding of blocks, transactions and the records kept next
+// to the chain is a sequence of fields in a fixed order, each written with
+// one of these primitives:
+//
+//	uint32, int64  fixed width, big-endian
+//	uvarint        unsigned LEB128 as written by binary.PutUvarint, used for
+//	               counts and lengths
+//	varint         zig-zag LEB128 as written by binary.PutVarint, used for
+//	               signed values
+//	bytes          a uvarint length followed by the bytes
+//	bool           one byte, 0 or 1
+//
+// Varints must use their shortest form, so every value has exactly one
+// encoding.
+
+// ErrMalformed is returned when data isn't in the canonical encoding: it is
+// truncated, has trailing bytes or a field isn't written in its only valid
+// form
+var ErrMalformed = errors.New("malformed encoding")
+
+// Writer builds a canonical encoding field by field
+type Writer struct {
+	buf bytes.Buffer
+}
+
+// Uint32 writes a fixed-width uint32
+func (w *Writer) Uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.buf.Write(b[:])
+}
+
+// Int64 writes a fixed-width int64
+func (w *Writer) Int64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	w.buf.Write(b[:])
+}
+
+// Uvarint writes an unsigned varint
+func (w *Writer) Uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+// Varint writes a signed varint
+func (w *Writer) Varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+// VarBytes writes a byte string prefixed with its length
+func (w *Writer) VarBytes(data []byte) {
+	w.Uvarint(uint64(len(data)))
+	w.buf.Write(data)
+}
+
+// Bool writes a boolean
+func (w *Writer) Bool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+// Data returns the encoding written so far
+func (w *Writer) Data() []byte {
+	return w.buf.Bytes()
+}
+
+// Reader decodes a canonical encoding field by field. The first error sticks:
+// later reads return zero values and Finish reports it.
+type Reader struct {
+	data []byte
+	err  error
+}
+
+// NewReader returns a Reader of the data
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+func (r *Reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data) {
+		r.err = ErrMalformed
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+
+	return b
+}
+
+// Uint32 reads a fixed-width uint32
+func (r *Reader) Uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(b)
+}
+
+// Int64 reads a fixed-width int64
+func (r *Reader) Int64() int64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// Uvarint reads an unsigned varint
+func (r *Reader) Uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	var b [binary.MaxVarintLen64]byte
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 || binary.PutUvarint(b[:], v) != n {
+		r.err = ErrMalformed
+		return 0
+	}
+	r.data = r.data[n:]
+
+	return v
+}
+
+// Varint reads a signed varint
+func (r *Reader) Varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	var b [binary.MaxVarintLen64]byte
+	v, n := binary.Varint(r.data)
+	if n <= 0 || binary.PutVarint(b[:], v) != n {
+		r.err = ErrMalformed
+		return 0
+	}
+	r.data = r.data[n:]
+
+	return v
+}
+
+// Int reads a signed varint that must fit an int
+func (r *Reader) Int() int {
+	v := r.Varint()
+	if v > math.MaxInt || v < math.MinInt {
+		r.err = ErrMalformed
+		return 0
+	}
+
+	return int(v)
+}
+
+// Count reads the number of elements of a list. Each element takes at least
+// one byte, so counts beyond the remaining data are rejected before anything
+// is allocated for them.
+func (r *Reader) Count() int {
+	n := r.Uvarint()
+	if n > uint64(len(r.data)) {
+		r.err = ErrMalformed
+		return 0
+	}
+
+	return int(n)
+}
+
+// VarBytes reads a byte string prefixed with its length. The empty string
+// reads as nil.
+func (r *Reader) VarBytes() []byte {
+	n := r.Uvarint()
+	if n > uint64(len(r.data)) {
+		r.err = ErrMalformed
+		return nil
+	}
+	if n == 0 {
+		return nil
+	}
+
+	return append([]byte{}, r.next(int(n))...)
+}
+
+// Bool reads a boolean
+func (r *Reader) Bool() bool {
+	b := r.next(1)
+	if b == nil {
+		return false
+	}
+	if b[0] > 1 {
+		r.err = ErrMalformed
+		return false
+	}
+
+	return b[0] == 1
+}
+
+// Finish returns the first error met, or ErrMalformed when bytes are left
+// after the last field
+func (r *Reader) Finish() error {
+	if r.err == nil && len(r.data) > 0 {
+		r.err = ErrMalformed
+	}
+
+	return r.err
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoding(t *testing.T) {
+	var w Writer
+	w.Uint32(7)
+	w.Int64(-2)
+	w.Uvarint(300)
+	w.Varint(-1)
+	w.VarBytes([]byte("abc"))
+	w.VarBytes(nil)
+	w.Bool(true)
+
+	r := NewReader(w.Data())
+	assert.Equal(t, uint32(7), r.Uint32())
+	assert.Equal(t, int64(-2), r.Int64())
+	assert.Equal(t, uint64(300), r.Uvarint())
+	assert.Equal(t, -1, r.Int())
+	assert.Equal(t, []byte("abc"), r.VarBytes())
+	assert.Nil(t, r.VarBytes())
+	assert.True(t, r.Bool())
+	assert.NoError(t, r.Finish())
+}
+
+func TestEncodingIsCanonical(t *testing.T) {
+	malformed := map[string]func(r *Reader){
+		"overlong varint":   func(r *Reader) { r.Uvarint() },
+		"boolean above one": func(r *Reader) { r.Bool() },
+		"truncated bytes":   func(r *Reader) { r.VarBytes() },
+		"huge count":        func(r *Reader) { r.Count() },
+		"trailing bytes":    func(r *Reader) { r.Uint32() },
+	}
+	data := map[string][]byte{
+		"overlong varint":   {0x81, 0x00},
+		"boolean above one": {2},
+		"truncated bytes":   {3, 'a', 'b'},
+		"huge count":        {0xff, 0xff, 0x03},
+		"trailing bytes":    {0, 0, 0, 1, 0},
+	}
+
+	for name, read := range malformed {
+		r := NewReader(data[name])
+		read(r)
+		assert.Equal(t, ErrMalformed, r.Finish(), name)
+	}
+}
@@ -0,0 +1,26 @@
+// Package utils holds byte conversion helpers shared by the other packages.
+package utils
+
+import (
+	"encoding/binary"
+)
+
+// IntToHex converts an int64 to a byte array
+func IntToHex(num int64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(num))
+
+	return data
+}
+
+// HexToInt converts a byte array produced by IntToHex back to an int64
+func HexToInt(data []byte) int64 {
+	return int64(binary.BigEndian.Uint64(data))
+}
+
+// ReverseBytes reverses a byte array
+func ReverseBytes(data []byte) {
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+}
@@ -1,8 +1,10 @@
-package main
+package wallet
 
 import (
 	"bytes"
 	"math/big"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/utils"
 )
 
 var b58Alphabet = []byte("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
@@ -23,11 +25,12 @@ func Base58Encode(input []byte) []byte {
 	}
 
 	// https://en.bitcoin.it/wiki/Base58Check_encoding#Version_bytes
-	if input[0] == 0x00 {
+	// Each leading zero byte is kept as a leading '1'
+	for i := 0; i < len(input) && input[i] == 0x00; i++ {
 		result = append(result, b58Alphabet[0])
 	}
 
-	ReverseBytes(result)
+	utils.ReverseBytes(result)
 
 	return result
 }
@@ -44,7 +47,7 @@ func Base58Decode(input []byte) []byte {
 
 	decoded := result.Bytes()
 
-	if input[0] == b58Alphabet[0] {
+	for i := 0; i < len(input) && input[i] == b58Alphabet[0]; i++ {
 		decoded = append([]byte{0x00}, decoded...)
 	}
 
@@ -1,4 +1,4 @@
-package main
+package wallet
 
 import (
 	"encoding/hex"
@@ -21,4 +21,8 @@ func TestBase58(t *testing.T) {
 
 	decoded := Base58Decode([]byte("16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM"))
 	assert.Equal(t, strings.ToLower("00010966776006953D5567439E5E39F86A0D273BEED61967F6"), hex.EncodeToString(decoded))
+
+	zeros := []byte{0x00, 0x00, 0x01}
+	assert.Equal(t, "112", string(Base58Encode(zeros)))
+	assert.Equal(t, zeros, Base58Decode(Base58Encode(zeros)), "every leading zero byte survives")
 }
@@ -1,4 +1,5 @@
-package main
+// Package wallet manages key pairs and the addresses derived from them.
+package wallet
 
 import (
 	"bytes"
@@ -6,14 +7,20 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
 	"log"
 
 	"golang.org/x/crypto/ripemd160"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
 )
 
-const version = byte(0x00)
 const addressChecksumLen = 4
 
+// ErrInvalidAddress is returned for addresses with a bad checksum or the
+// version byte of another network
+var ErrInvalidAddress = errors.New("address is not valid")
+
 // Wallet stores private and public keys
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
@@ -21,18 +28,21 @@ type Wallet struct {
 }
 
 // NewWallet creates and returns a Wallet
-func NewWallet() *Wallet {
-	private, public := newKeyPair()
+func NewWallet() (*Wallet, error) {
+	private, public, err := newKeyPair()
+	if err != nil {
+		return nil, err
+	}
 	wallet := Wallet{private, public}
 
-	return &wallet
+	return &wallet, nil
 }
 
 // GetAddress returns wallet address
 func (w Wallet) GetAddress() []byte {
 	pubKeyHash := HashPubKey(w.PublicKey)
 
-	versionedPayload := append([]byte{version}, pubKeyHash...)
+	versionedPayload := append([]byte{params.Active.AddressVersion}, pubKeyHash...)
 	checksum := checksum(versionedPayload)
 
 	fullPayload := append(versionedPayload, checksum...)
@@ -55,15 +65,18 @@ func HashPubKey(pubKey []byte) []byte {
 	return publicRIPEMD160
 }
 
-// ValidateAddress check if address if valid
+// ValidateAddress check if address if valid on the network
 func ValidateAddress(address string) bool {
 	pubKeyHash := Base58Decode([]byte(address))
+	if len(pubKeyHash) <= addressChecksumLen {
+		return false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
 	version := pubKeyHash[0]
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
 	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
 
-	return bytes.Compare(actualChecksum, targetChecksum) == 0
+	return version == params.Active.AddressVersion && bytes.Compare(actualChecksum, targetChecksum) == 0
 }
 
 // Checksum generates a checksum for a public key
@@ -74,13 +87,14 @@ func checksum(payload []byte) []byte {
 	return secondSHA[:addressChecksumLen]
 }
 
-func newKeyPair() (ecdsa.PrivateKey, []byte) {
+func newKeyPair() (ecdsa.PrivateKey, []byte, error) {
 	curve := elliptic.P256()
 	private, err := ecdsa.GenerateKey(curve, rand.Reader)
 	if err != nil {
-		log.Panic(err)
+		return ecdsa.PrivateKey{}, nil, err
 	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	size := curve.Params().BitSize / 8
+	pubKey := append(private.PublicKey.X.FillBytes(make([]byte, size)), private.PublicKey.Y.FillBytes(make([]byte, size))...)
 
-	return *private, pubKey
+	return *private, pubKey, nil
 }
@@ -0,0 +1,23 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aQuaYi/Blockchain-in-Go/source/params"
+)
+
+func TestValidateAddress(t *testing.T) {
+	saved := params.Active
+	defer func() { params.Active = saved }()
+
+	params.Active = &params.TestNet
+	w, err := NewWallet()
+	assert.NoError(t, err)
+	address := string(w.GetAddress())
+	assert.True(t, ValidateAddress(address))
+
+	params.Active = &params.MainNet
+	assert.False(t, ValidateAddress(address), "addresses are bound to their network")
+}
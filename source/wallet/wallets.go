@@ -1,17 +1,21 @@
-package main
+package wallet
 
 import (
 	"bytes"
 	"crypto/elliptic"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 )
 
 const walletFile = "wallet_%s.dat"
 
+// ErrWalletNotFound is returned when the wallet file has no key for an
+// address
+var ErrWalletNotFound = errors.New("the wallet file has no key for this address")
+
 // Wallets stores a collection of wallets
 type Wallets struct {
 	Wallets map[string]*Wallet
@@ -28,13 +32,16 @@ func NewWallets(nodeID string) (*Wallets, error) {
 }
 
 // CreateWallet adds a Wallet to Wallets
-func (ws *Wallets) CreateWallet() string {
-	wallet := NewWallet()
+func (ws *Wallets) CreateWallet() (string, error) {
+	wallet, err := NewWallet()
+	if err != nil {
+		return "", err
+	}
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 
 	ws.Wallets[address] = wallet
 
-	return address
+	return address, nil
 }
 
 // GetAddresses returns an array of addresses stored in the wallet file
@@ -49,8 +56,13 @@ func (ws *Wallets) GetAddresses() []string {
 }
 
 // GetWallet returns a Wallet by its address
-func (ws Wallets) GetWallet(address string) Wallet {
-	return *ws.Wallets[address]
+func (ws Wallets) GetWallet(address string) (Wallet, error) {
+	wallet, ok := ws.Wallets[address]
+	if !ok {
+		return Wallet{}, fmt.Errorf("%w: %s", ErrWalletNotFound, address)
+	}
+
+	return *wallet, nil
 }
 
 // LoadFromFile loads wallets from the file
@@ -62,7 +74,7 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 
 	fileContent, err := ioutil.ReadFile(walletFile)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	var wallets Wallets
@@ -70,7 +82,7 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
 	err = decoder.Decode(&wallets)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("%s: %v", walletFile, err)
 	}
 
 	ws.Wallets = wallets.Wallets
@@ -79,7 +91,7 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 }
 
 // SaveToFile saves wallets to a file
-func (ws Wallets) SaveToFile(nodeID string) {
+func (ws Wallets) SaveToFile(nodeID string) error {
 	var content bytes.Buffer
 	walletFile := fmt.Sprintf(walletFile, nodeID)
 
@@ -88,11 +100,8 @@ func (ws Wallets) SaveToFile(nodeID string) {
 	encoder := gob.NewEncoder(&content)
 	err := encoder.Encode(ws)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
-	if err != nil {
-		log.Panic(err)
-	}
+	return ioutil.WriteFile(walletFile, content.Bytes(), 0644)
 }